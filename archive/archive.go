@@ -0,0 +1,329 @@
+// Package archive packs directory trees into compressed tarballs and unpacks
+// them again, keeping permissions, modification times, symlinks and hardlinks
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	FormatZstd = "zst"
+	FormatGzip = "gz"
+)
+
+var Formats = []string{FormatZstd, FormatGzip}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Ext is the file extension of archives in format
+func Ext(format string) string {
+	if format == FormatGzip {
+		return ".tar.gz"
+	}
+	return ".tar.zst"
+}
+
+// Create packs the tree at dir into a new file at dst and returns its size.
+// Entries are stored relative to dir. A partially written dst is removed on
+// failure.
+func Create(ctx context.Context, dir, dst, format string) (size int64, err error) {
+	if format != FormatZstd && format != FormatGzip {
+		return 0, fmt.Errorf("unknown archive format %q (want one of %v)", format, Formats)
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	buf := bufio.NewWriter(f)
+	var zw io.WriteCloser
+	if format == FormatGzip {
+		zw = gzip.NewWriter(buf)
+	} else if zw, err = zstd.NewWriter(buf); err != nil {
+		return 0, err
+	}
+
+	tw := tar.NewWriter(zw)
+	if err := writeTree(ctx, tw, dir); err != nil {
+		return 0, err
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	if err := buf.Flush(); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func writeTree(ctx context.Context, tw *tar.Writer, dir string) error {
+	// First path seen for every file with more than one link
+	links := make(map[fileID]string)
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// Links are stored as they are, workspace packages point outside the
+		// tree and Extract never writes through them
+		var target string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, target)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if info.Mode().IsRegular() {
+			if id, ok := idOf(info); ok {
+				if first, seen := links[id]; seen {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = first
+					hdr.Size = 0
+				} else {
+					links[id] = name
+				}
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		return copyFile(tw, path)
+	})
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Extract unpacks the archive at src into dir, which must not exist yet. A
+// partially unpacked dir is removed on failure.
+func Extract(ctx context.Context, src, dir string) error {
+	dir = filepath.Clean(dir)
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := bufio.NewReader(f)
+	magic, err := buf.Peek(4)
+	if err != nil {
+		return fmt.Errorf("%s is not an archive: %w", src, err)
+	}
+	var zr io.Reader
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		zr = gz
+	case bytes.HasPrefix(magic, zstdMagic):
+		zd, err := zstd.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		defer zd.Close()
+		zr = zd
+	default:
+		return fmt.Errorf("%s is not a gzip or zstd archive", src)
+	}
+
+	if _, err := os.Lstat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := readTree(ctx, tar.NewReader(zr), dir); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	return nil
+}
+
+func readTree(ctx context.Context, tr *tar.Reader, dir string) error {
+	// Every write goes through root, which refuses paths leaving dir, also
+	// through symlinks unpacked earlier. The links themselves may point
+	// anywhere, like the workspace packages they were archived with.
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	// Directory modes and times are applied last, a read only directory
+	// would stop its own children from being written and writing children
+	// changes its modification time
+	type dirMeta struct {
+		name    string
+		mode    fs.FileMode
+		modTime time.Time
+	}
+	var dirs []dirMeta
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := within(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirMeta{name, mode.Perm(), hdr.ModTime})
+			continue
+		case tar.TypeReg:
+			if err := writeFile(root, name, tr, mode.Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+				return err
+			}
+			if err := root.Symlink(hdr.Linkname, name); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeLink:
+			target, err := within(dir, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := root.Link(target, name); err != nil {
+				return err
+			}
+			continue
+		default:
+			// Devices and fifos have no business in node_modules
+			continue
+		}
+
+		if err := root.Chmod(name, mode.Perm()); err != nil {
+			return err
+		}
+		if err := root.Chtimes(name, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	// Children before parents
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := root.Chmod(d.name, d.mode); err != nil {
+			return err
+		}
+		if err := root.Chtimes(d.name, d.modTime, d.modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(root *os.Root, name string, r io.Reader, perm fs.FileMode) error {
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// within turns an entry name into a path relative to dir, refusing names
+// that would escape it
+func within(dir, name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("archive entry %q points outside %s", name, dir)
+	}
+	return rel, nil
+}
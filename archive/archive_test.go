@@ -0,0 +1,165 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTarball packs hdrs, regular files get their name as content
+func writeTarball(t *testing.T, path string, hdrs []*tar.Header) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(hdr.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractRefusesEscapes(t *testing.T) {
+	tmp := t.TempDir()
+	outside := filepath.Join(tmp, "outside")
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		hdrs []*tar.Header
+	}{
+		{"dot dot name", []*tar.Header{
+			{Name: "../outside/x", Typeflag: tar.TypeReg},
+		}},
+		{"absolute symlink then write through it", []*tar.Header{
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "a/x", Typeflag: tar.TypeReg},
+		}},
+		{"relative symlink leaving the tree", []*tar.Header{
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "../outside"},
+			{Name: "a/x", Typeflag: tar.TypeReg},
+		}},
+		{"write through a link made through a symlinked parent", []*tar.Header{
+			{Name: "s", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "s/s/l", Typeflag: tar.TypeSymlink, Linkname: "../outside"},
+			{Name: "l/x", Typeflag: tar.TypeReg},
+		}},
+		{"hardlink through a link leaving the tree", []*tar.Header{
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "../outside"},
+			{Name: "x", Typeflag: tar.TypeLink, Linkname: "a/secret"},
+		}},
+		{"hardlink outside", []*tar.Header{
+			{Name: "x", Typeflag: tar.TypeLink, Linkname: "../outside/secret"},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := filepath.Join(tmp, "evil.tar.gz")
+			writeTarball(t, src, tc.hdrs)
+			dir := filepath.Join(tmp, "restore")
+			if err := Extract(context.Background(), src, dir); err == nil {
+				t.Fatal("expected the archive to be refused")
+			}
+			entries, err := os.ReadDir(outside)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Name() != "secret" {
+				t.Errorf("something was written outside: %v", entries)
+			}
+			if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed, got %v", dir, err)
+			}
+		})
+	}
+}
+
+func TestExtractFollowsInnerSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "ok.tar.gz")
+	writeTarball(t, src, []*tar.Header{
+		{Name: "pkg/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "pkg"},
+		{Name: "link/index.js", Typeflag: tar.TypeReg},
+		{Name: ".bin/cli", Typeflag: tar.TypeSymlink, Linkname: "../pkg/index.js"},
+	})
+	dir := filepath.Join(tmp, "restore")
+	if err := Extract(context.Background(), src, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".bin", "cli"))
+	if err != nil || string(data) != "link/index.js" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func TestOutsideLinksRoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "node_modules")
+	if err := os.MkdirAll(filepath.Join(dir, "left-pad"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "left-pad", "index.js"), []byte("pad"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Workspace packages are linked from outside the tree, relative and absolute
+	links := map[string]string{
+		"lib":  "../packages/lib",
+		"util": filepath.Join(tmp, "packages", "util"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(tmp, "out.tar.zst")
+	if _, err := Create(context.Background(), dir, dst, FormatZstd); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Extract(context.Background(), dst, dir); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	for name, want := range links {
+		if got, err := os.Readlink(filepath.Join(dir, name)); err != nil || got != want {
+			t.Errorf("%s: expected a link to %s, got %q %v", name, want, got, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "left-pad", "index.js")); err != nil || string(data) != "pad" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(tmp, "packages")); !os.IsNotExist(err) {
+		t.Errorf("something was written outside: %v", err)
+	}
+}
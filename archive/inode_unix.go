@@ -0,0 +1,21 @@
+//go:build !windows
+
+package archive
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+type fileID struct {
+	dev, ino uint64
+}
+
+// idOf identifies files with more than one hardlink
+func idOf(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ArchiveItem is a tree that was packed into an archive before it was
+// deleted, it can be unpacked back in place
+type ArchiveItem struct {
+	ID             int64     `json:"id"`
+	OriginalPath   string    `json:"original_path"`
+	ArchivePath    string    `json:"archive_path"`
+	Size           int64     `json:"size"`
+	ArchiveSize    int64     `json:"archive_size"`
+	PackageManager string    `json:"package_manager"`
+	ProjectName    string    `json:"project_name"`
+	ArchivedAt     time.Time `json:"archived_at"`
+}
+
+func (c *Cache) AddArchive(item *ArchiveItem) error {
+	query := `
+        INSERT INTO archives (original_path, archive_path, size, archive_size, package_manager, project_name, archived_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `
+	return c.withWriteLock(func() error {
+		res, err := c.db.Exec(query, item.OriginalPath, item.ArchivePath, item.Size, item.ArchiveSize, item.PackageManager, item.ProjectName, item.ArchivedAt.Unix())
+		if err != nil {
+			return err
+		}
+		item.ID, err = res.LastInsertId()
+		return err
+	})
+}
+
+func (c *Cache) RemoveArchive(id int64) error {
+	return c.withWriteLock(func() error {
+		_, err := c.db.Exec("DELETE FROM archives WHERE id = ?", id)
+		return err
+	})
+}
+
+func (c *Cache) GetArchive(id int64) (*ArchiveItem, error) {
+	row := c.db.QueryRow(archiveSelect+" WHERE id = ?", id)
+	item, err := scanArchive(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return item, err
+}
+
+func (c *Cache) Archives() ([]*ArchiveItem, error) {
+	rows, err := c.db.Query(archiveSelect + " ORDER BY archived_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*ArchiveItem
+	for rows.Next() {
+		item, err := scanArchive(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+const archiveSelect = `
+    SELECT id, original_path, archive_path, size, archive_size, package_manager, project_name, archived_at
+    FROM archives`
+
+func scanArchive(row interface{ Scan(...any) error }) (*ArchiveItem, error) {
+	var item ArchiveItem
+	var archivedUnix int64
+	err := row.Scan(&item.ID, &item.OriginalPath, &item.ArchivePath, &item.Size, &item.ArchiveSize, &item.PackageManager, &item.ProjectName, &archivedUnix)
+	if err != nil {
+		return nil, err
+	}
+	item.ArchivedAt = time.Unix(archivedUnix, 0)
+	return &item, nil
+}
+
+func (m *MemoryStore) AddArchive(item *ArchiveItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.archiveSeq++
+	item.ID = m.archiveSeq
+	m.archives = append(m.archives, *item)
+	return nil
+}
+
+func (m *MemoryStore) RemoveArchive(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.archives {
+		if m.archives[i].ID == id {
+			m.archives = append(m.archives[:i], m.archives[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetArchive(id int64) (*ArchiveItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, item := range m.archives {
+		if item.ID == id {
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) Archives() ([]*ArchiveItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]*ArchiveItem, 0, len(m.archives))
+	for i := len(m.archives) - 1; i >= 0; i-- {
+		item := m.archives[i]
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+func (s *JSONStore) AddArchive(item *ArchiveItem) error {
+	if err := s.MemoryStore.AddArchive(item); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func (s *JSONStore) RemoveArchive(id int64) error {
+	if err := s.MemoryStore.RemoveArchive(id); err != nil {
+		return err
+	}
+	return s.Flush()
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBatchSize     = 256
+	DefaultFlushInterval = 500 * time.Millisecond
+)
+
+// BatchWriter buffers writes and flushes them to the store in a single
+// transaction whenever maxSize entries are pending, every interval, and on
+// Close. Writes are only durable after a flush, a failed flush keeps its
+// entries for the next one.
+type BatchWriter struct {
+	store    Store
+	maxSize  int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []*CacheEntry
+
+	// flushMu serialises flushes so batches are committed in order
+	flushMu sync.Mutex
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewBatchWriter(store Store, maxSize int, interval time.Duration) *BatchWriter {
+	if maxSize <= 0 {
+		maxSize = DefaultBatchSize
+	}
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	b := &BatchWriter{
+		store:    store,
+		maxSize:  maxSize,
+		interval: interval,
+		pending:  make([]*CacheEntry, 0, maxSize),
+		stop:     make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+func (b *BatchWriter) loop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.Flush()
+		}
+	}
+}
+
+// Put queues the entry, flushing in the caller's goroutine if the batch is full
+func (b *BatchWriter) Put(entry *CacheEntry) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, entry)
+	// Entries kept from a failed flush don't make every Put try again
+	full := len(b.pending)%b.maxSize == 0
+	b.mu.Unlock()
+
+	if full {
+		return b.Flush()
+	}
+	return nil
+}
+
+// Flush writes all pending entries now
+func (b *BatchWriter) Flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = make([]*CacheEntry, 0, b.maxSize)
+	b.mu.Unlock()
+
+	err := b.store.InsertOrUpdateBatch(batch)
+	if err != nil {
+		// Ahead of anything queued meanwhile, so a later entry for the same
+		// path still wins
+		b.mu.Lock()
+		b.pending = append(batch, b.pending...)
+		b.mu.Unlock()
+	}
+	return err
+}
+
+// Close stops the background flusher and writes whatever is left, including
+// entries of earlier failed flushes. It returns the error of that last flush,
+// the entries are lost then. The underlying store is not closed.
+func (b *BatchWriter) Close() error {
+	var err error
+	b.once.Do(func() {
+		close(b.stop)
+		b.wg.Wait()
+		err = b.Flush()
+	})
+	return err
+}
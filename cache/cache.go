@@ -2,25 +2,33 @@ package cache
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 type CacheEntry struct {
-	Path           string
-	Size           int64
-	LastModifiedAt time.Time
-	ScannedAt      time.Time
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	LastModifiedAt time.Time `json:"last_modified_at"`
+	// ScannedAt is when a scan last saw the tree, from the cache or measuring it
+	ScannedAt time.Time `json:"scanned_at"`
 }
 
+// Cache is the SQLite backed Store
 type Cache struct {
-	db *sql.DB
+	db   *sql.DB
+	path string
+	lock *fileLock
 }
 
+var _ Store = (*Cache)(nil)
+
 const schema = `
 CREATE TABLE IF NOT EXISTS node_modules (
     path TEXT PRIMARY KEY,
@@ -28,10 +36,54 @@ CREATE TABLE IF NOT EXISTS node_modules (
     last_modified_at INTEGER NOT NULL,
     scanned_at INTEGER NOT NULL
 );
+
+CREATE TABLE IF NOT EXISTS deletions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    path TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    package_manager TEXT NOT NULL DEFAULT '',
+    project_name TEXT NOT NULL DEFAULT '',
+    deleted_at INTEGER NOT NULL,
+    outcome TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_deletions_deleted_at ON deletions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    root TEXT NOT NULL,
+    started_at INTEGER NOT NULL,
+    finished_at INTEGER NOT NULL DEFAULT 0,
+    entries INTEGER NOT NULL DEFAULT 0,
+    file_count INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS quarantine (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    original_path TEXT NOT NULL,
+    staged_path TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    package_manager TEXT NOT NULL DEFAULT '',
+    project_name TEXT NOT NULL DEFAULT '',
+    quarantined_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    original_path TEXT NOT NULL,
+    archive_path TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    archive_size INTEGER NOT NULL,
+    package_manager TEXT NOT NULL DEFAULT '',
+    project_name TEXT NOT NULL DEFAULT '',
+    archived_at INTEGER NOT NULL
+);
 `
 
 func NewCache() (*Cache, error) {
-	cacheDir, err := getCacheDir()
+	cacheDir, err := ResolveDir("", "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cache directory: %w", err)
 	}
@@ -40,38 +92,96 @@ func NewCache() (*Cache, error) {
 		return nil, fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	dbPath := filepath.Join(cacheDir, "npmclean.db")
+	return Open(filepath.Join(cacheDir, "npmclean.db"))
+}
+
+// Open opens (creating if needed) the cache database at dbPath. Several
+// processes may share the database, their writes are serialised through an
+// advisory lock on dbPath.lock and fail with a *LockedError if the lock can't
+// be acquired within LockTimeout.
+func Open(dbPath string) (*Cache, error) {
+	lock, err := newFileLock(dbPath + ".lock")
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
+		lock.Close()
 		return nil, err
 	}
 
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
 
-	db.Exec(`PRAGMA journal_mode=WAL;`)
-	db.Exec(`PRAGMA synchronous=NORMAL;`)
-	db.Exec(`PRAGMA busy_timeout=5000;`)
-	db.Exec(`PRAGMA temp_store=MEMORY;`)
-	db.Exec(`PRAGMA mmap_size=30000000000;`)
+	c := &Cache{db: db, path: dbPath, lock: lock}
 
-	if _, err := db.Exec(schema); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+	// busy_timeout comes first, the others may already need to wait for a
+	// concurrent writer
+	pragmas := []string{
+		`PRAGMA busy_timeout=5000;`,
+		`PRAGMA journal_mode=WAL;`,
+		`PRAGMA synchronous=NORMAL;`,
+		`PRAGMA temp_store=MEMORY;`,
+		`PRAGMA mmap_size=30000000000;`,
+	}
+	err = c.withWriteLock(func() error {
+		for _, pragma := range pragmas {
+			if _, err := db.Exec(pragma); err != nil {
+				return fmt.Errorf("failed to set %s: %w", strings.TrimSuffix(pragma, ";"), err)
+			}
+		}
+		if _, err := db.Exec(schema); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Close()
+		return nil, err
 	}
 
-	return &Cache{db: db}, nil
+	return c, nil
+}
+
+// withWriteLock runs fn holding the cross process write lock
+func (c *Cache) withWriteLock(fn func() error) error {
+	if err := c.lock.Lock(LockTimeout); err != nil {
+		return err
+	}
+	defer c.lock.Unlock()
+	return fn()
 }
 
 func (c *Cache) Close() error {
+	c.lock.Close()
 	if c.db != nil {
 		return c.db.Close()
 	}
 	return nil
 }
 
+// EnvCacheDir overrides the cache directory, it takes precedence over the
+// config file but not over an explicit flag
+const EnvCacheDir = "NPMCLEAN_CACHE_DIR"
+
+// ResolveDir picks the cache directory: override (from a flag), then
+// $NPMCLEAN_CACHE_DIR, then configured (from the config file), then
+// $XDG_CACHE_HOME/npmclean and finally ~/.cache/npmclean
+func ResolveDir(override, configured string) (string, error) {
+	for _, dir := range []string{override, os.Getenv(EnvCacheDir), configured} {
+		if dir != "" {
+			return filepath.Abs(dir)
+		}
+	}
+	return getCacheDir()
+}
+
 func getCacheDir() (string, error) {
+	// Per the XDG base directory spec relative paths must be ignored
+	if dir := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "npmclean"), nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -79,8 +189,7 @@ func getCacheDir() (string, error) {
 	return filepath.Join(home, ".cache", "npmclean"), nil
 }
 
-func (c *Cache) InsertOrUpdate(entry *CacheEntry) error {
-	query := `
+const upsertQuery = `
         INSERT INTO node_modules (path, size, last_modified_at, scanned_at)
         VALUES (?, ?, ?, ?)
         ON CONFLICT(path) DO UPDATE SET
@@ -88,8 +197,41 @@ func (c *Cache) InsertOrUpdate(entry *CacheEntry) error {
             last_modified_at = excluded.last_modified_at,
             scanned_at = excluded.scanned_at
     `
-	_, err := c.db.Exec(query, entry.Path, entry.Size, entry.LastModifiedAt.Unix(), entry.ScannedAt.Unix())
-	return err
+
+func (c *Cache) InsertOrUpdate(entry *CacheEntry) error {
+	return c.withWriteLock(func() error {
+		_, err := c.db.Exec(upsertQuery, entry.Path, entry.Size, entry.LastModifiedAt.Unix(), entry.ScannedAt.Unix())
+		return err
+	})
+}
+
+// InsertOrUpdateBatch writes all entries in a single transaction
+func (c *Cache) InsertOrUpdateBatch(entries []*CacheEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return c.withWriteLock(func() error { return c.insertBatch(entries) })
+}
+
+func (c *Cache) insertBatch(entries []*CacheEntry) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(upsertQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		if _, err := stmt.Exec(entry.Path, entry.Size, entry.LastModifiedAt.Unix(), entry.ScannedAt.Unix()); err != nil {
+			return fmt.Errorf("failed to insert %q: %w", entry.Path, err)
+		}
+	}
+	return tx.Commit()
 }
 
 func (c *Cache) GetAll() ([]*CacheEntry, error) {
@@ -97,6 +239,33 @@ func (c *Cache) GetAll() ([]*CacheEntry, error) {
 	if err != nil {
 		return nil, err
 	}
+	return scanEntries(rows)
+}
+
+func (c *Cache) RangePrefix(prefix string, fn func(*CacheEntry) bool) error {
+	rows, err := c.db.Query(`
+        SELECT path, size, last_modified_at, scanned_at FROM node_modules
+        WHERE substr(path, 1, length(?1)) = ?1 ORDER BY path
+    `, prefix)
+	if err != nil {
+		return err
+	}
+
+	// Read everything up front, we only have a single connection and fn is
+	// allowed to write to the cache
+	entries, err := scanEntries(rows)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !fn(entry) {
+			break
+		}
+	}
+	return nil
+}
+
+func scanEntries(rows *sql.Rows) ([]*CacheEntry, error) {
 	defer rows.Close()
 
 	var entries []*CacheEntry
@@ -119,14 +288,19 @@ func (c *Cache) GetAll() ([]*CacheEntry, error) {
 }
 
 func (c *Cache) Delete(path string) error {
-	_, err := c.db.Exec("DELETE FROM node_modules WHERE path = ?", path)
-	return err
+	return c.withWriteLock(func() error {
+		_, err := c.db.Exec("DELETE FROM node_modules WHERE path = ?", path)
+		return err
+	})
 }
 
 func (c *Cache) Get(path string) (*CacheEntry, error) {
 	var size int64
 	var lastModUnix, scannedUnix int64
 	err := c.db.QueryRow("SELECT size, last_modified_at, scanned_at FROM node_modules WHERE path = ?", path).Scan(&size, &lastModUnix, &scannedUnix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,472 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const benchEntries = 5000
+
+func openTestCache(tb testing.TB) *Cache {
+	tb.Helper()
+	c, err := Open(filepath.Join(tb.TempDir(), "npmclean.db"))
+	if err != nil {
+		tb.Fatalf("open cache: %v", err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c
+}
+
+func testEntries(n int) []*CacheEntry {
+	now := time.Now()
+	entries := make([]*CacheEntry, n)
+	for i := range entries {
+		entries[i] = &CacheEntry{
+			Path:           fmt.Sprintf("/home/user/project-%d/node_modules", i),
+			Size:           int64(i) * 4096,
+			LastModifiedAt: now,
+			ScannedAt:      now,
+		}
+	}
+	return entries
+}
+
+func TestBatchWriterFlushesOnClose(t *testing.T) {
+	c := openTestCache(t)
+	entries := testEntries(1000)
+
+	// Large batch and interval so nothing but Close can flush the tail
+	w := NewBatchWriter(c, 300, time.Hour)
+	for _, e := range entries {
+		if err := w.Put(e); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	all, err := c.GetAll()
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	if len(all) != len(entries) {
+		t.Fatalf("expected %d entries, got %d", len(entries), len(all))
+	}
+}
+
+// flakyStore fails as many batch writes as fails says
+type flakyStore struct {
+	*MemoryStore
+	fails int
+}
+
+func (f *flakyStore) InsertOrUpdateBatch(entries []*CacheEntry) error {
+	if f.fails > 0 {
+		f.fails--
+		return errors.New("database is locked")
+	}
+	return f.MemoryStore.InsertOrUpdateBatch(entries)
+}
+
+func TestBatchWriterKeepsFailedBatches(t *testing.T) {
+	s := &flakyStore{MemoryStore: NewMemoryStore(), fails: 1}
+	entries := testEntries(25)
+
+	w := NewBatchWriter(s, 10, time.Hour)
+	for i, e := range entries {
+		err := w.Put(e)
+		if i == 9 && err == nil {
+			t.Fatal("expected the first flush to fail")
+		}
+		if i != 9 && err != nil {
+			t.Fatalf("put %d: %v", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if all, _ := s.GetAll(); len(all) != len(entries) {
+		t.Fatalf("expected %d entries, got %d", len(entries), len(all))
+	}
+
+	// Close reports its own flush, not an older one
+	s.fails = 1
+	w = NewBatchWriter(s, 10, time.Hour)
+	w.Put(entries[0])
+	if err := w.Close(); err == nil {
+		t.Fatal("expected close to report the failed flush")
+	}
+}
+
+func BenchmarkInsertOrUpdate(b *testing.B) {
+	c := openTestCache(b)
+	entries := testEntries(benchEntries)
+	for b.Loop() {
+		for _, e := range entries {
+			if err := c.InsertOrUpdate(e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchWriter(b *testing.B) {
+	c := openTestCache(b)
+	entries := testEntries(benchEntries)
+	for b.Loop() {
+		w := NewBatchWriter(c, DefaultBatchSize, DefaultFlushInterval)
+		for _, e := range entries {
+			if err := w.Put(e); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// testStores opens an empty store of every backend
+var testStores = map[string]func(t *testing.T) Store{
+	BackendSQLite: func(t *testing.T) Store { return openTestCache(t) },
+	BackendMemory: func(t *testing.T) Store { return NewMemoryStore() },
+	BackendJSON: func(t *testing.T) Store {
+		s, err := OpenJSON(filepath.Join(t.TempDir(), "npmclean.json"))
+		if err != nil {
+			t.Fatalf("open json: %v", err)
+		}
+		return s
+	},
+}
+
+func TestStores(t *testing.T) {
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+
+			if err := s.InsertOrUpdateBatch(testEntries(20)); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			if _, err := s.Get("/nope"); err != ErrNotFound {
+				t.Fatalf("expected ErrNotFound, got %v", err)
+			}
+			if err := s.Delete("/home/user/project-1/node_modules"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+
+			// project-1 is gone, project-10..19 remain
+			var paths []string
+			err := s.RangePrefix("/home/user/project-1", func(e *CacheEntry) bool {
+				paths = append(paths, e.Path)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("range: %v", err)
+			}
+			if len(paths) != 10 || paths[0] != "/home/user/project-10/node_modules" {
+				t.Fatalf("unexpected range result: %v", paths)
+			}
+
+			session, err := s.StartSession("/home/user")
+			if err != nil {
+				t.Fatalf("start session: %v", err)
+			}
+			session.Entries = 19
+			if err := s.FinishSession(session); err != nil {
+				t.Fatalf("finish session: %v", err)
+			}
+			sessions, err := s.Sessions()
+			if err != nil || len(sessions) != 1 || sessions[0].Entries != 19 || sessions[0].FinishedAt.IsZero() {
+				t.Fatalf("unexpected sessions: %v %v", sessions, err)
+			}
+
+			archived := &ArchiveItem{OriginalPath: "/p/node_modules", ArchivePath: "/a/1.tar.zst", Size: 100, ArchiveSize: 20, ArchivedAt: time.Now()}
+			if err := s.AddArchive(archived); err != nil {
+				t.Fatalf("add archive: %v", err)
+			}
+			if got, err := s.GetArchive(archived.ID); err != nil || got.ArchivePath != archived.ArchivePath || got.ArchiveSize != 20 {
+				t.Fatalf("get archive: %+v %v", got, err)
+			}
+			if err := s.RemoveArchive(archived.ID); err != nil {
+				t.Fatalf("remove archive: %v", err)
+			}
+			if items, err := s.Archives(); err != nil || len(items) != 0 {
+				t.Fatalf("expected no archives, got %v %v", items, err)
+			}
+		})
+	}
+}
+
+func TestDeletions(t *testing.T) {
+	at := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 12, 0, 0, 0, time.Local)
+	}
+	records := []DeletionRecord{
+		{Path: "/a/node_modules", Size: 100, PackageManager: "npm", ProjectName: "a", DeletedAt: at(1, 5), Outcome: OutcomeDeleted},
+		{Path: "/b/node_modules", Size: 999, DeletedAt: at(1, 6), Outcome: OutcomeFailed, Error: "permission denied"},
+		{Path: "/c/node_modules", Size: 50, DeletedAt: at(1, 20), Outcome: OutcomeDeleted},
+		{Path: "/d/node_modules", Size: 70, DeletedAt: at(1, 21), Outcome: OutcomeCancelled, Error: "context canceled"},
+		{Path: "/e/node_modules", Size: 300, DeletedAt: at(2, 1), Outcome: OutcomeTrashed},
+		{Path: "/f/node_modules", Size: 200, DeletedAt: at(2, 2), Outcome: OutcomeDeleted},
+		{Path: "/g/node_modules", Size: 80, DeletedAt: at(3, 3), Outcome: OutcomeCancelled},
+	}
+
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+
+			for i := range records {
+				rec := records[i]
+				if err := s.RecordDeletion(&rec); err != nil {
+					t.Fatalf("record: %v", err)
+				}
+				if rec.ID == 0 {
+					t.Fatalf("record %s got no id", rec.Path)
+				}
+			}
+
+			all, err := s.Deletions(time.Time{})
+			if err != nil || len(all) != len(records) {
+				t.Fatalf("expected %d deletions, got %d %v", len(records), len(all), err)
+			}
+			// Newest first, with every field kept
+			for i, got := range all {
+				want := records[len(records)-1-i]
+				if got.Path != want.Path || got.Size != want.Size || got.Outcome != want.Outcome || got.Error != want.Error ||
+					got.PackageManager != want.PackageManager || got.ProjectName != want.ProjectName || !got.DeletedAt.Equal(want.DeletedAt) {
+					t.Errorf("deletion %d: expected %+v, got %+v", i, want, *got)
+				}
+			}
+
+			since, err := s.Deletions(at(2, 1))
+			if err != nil || len(since) != 3 || since[2].Path != "/e/node_modules" {
+				t.Fatalf("expected the 3 deletions since February, got %d %v", len(since), err)
+			}
+
+			// Only trees that are really gone count, not failed, cancelled or
+			// trashed ones
+			totals := SummarizeDeletions(all)
+			want := []DeletionTotal{{Month: "2026-02", Count: 1, Bytes: 200}, {Month: "2026-01", Count: 2, Bytes: 150}}
+			if len(totals) != len(want) {
+				t.Fatalf("expected %v, got %d totals", want, len(totals))
+			}
+			for i := range want {
+				if *totals[i] != want[i] {
+					t.Errorf("total %d: expected %+v, got %+v", i, want[i], *totals[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJSONStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "npmclean.json")
+	s, err := OpenJSON(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	s.InsertOrUpdateBatch(testEntries(3))
+	s.RecordDeletion(&DeletionRecord{Path: "/p/node_modules", Size: 10, DeletedAt: time.Now(), Outcome: OutcomeDeleted})
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	s, err = OpenJSON(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	all, _ := s.GetAll()
+	deletions, _ := s.Deletions(time.Time{})
+	if len(all) != 3 || len(deletions) != 1 {
+		t.Fatalf("expected 3 entries and 1 deletion, got %d and %d", len(all), len(deletions))
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "npmclean.db")
+
+	// Two handles on the same database behave like two processes, the
+	// advisory lock is per open file
+	writers := make([]*Cache, 2)
+	for i := range writers {
+		c, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("open writer %d: %v", i, err)
+		}
+		defer c.Close()
+		writers[i] = c
+	}
+
+	const perWriter = 500
+	errs := make(chan error, len(writers))
+	for i, c := range writers {
+		go func() {
+			entries := testEntries(perWriter * len(writers))[i*perWriter : (i+1)*perWriter]
+			for j, e := range entries {
+				var err error
+				if j%2 == 0 {
+					err = c.InsertOrUpdate(e)
+				} else {
+					err = c.InsertOrUpdateBatch([]*CacheEntry{e})
+				}
+				if err != nil {
+					errs <- fmt.Errorf("writer %d: %w", i, err)
+					return
+				}
+			}
+			errs <- c.RecordDeletion(&DeletionRecord{Path: entries[0].Path, DeletedAt: time.Now(), Outcome: OutcomeDeleted})
+		}()
+	}
+	for range writers {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := writers[0].GetAll()
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	if len(all) != perWriter*len(writers) {
+		t.Fatalf("expected %d entries, got %d", perWriter*len(writers), len(all))
+	}
+	deletions, _ := writers[1].Deletions(time.Time{})
+	if len(deletions) != len(writers) {
+		t.Fatalf("expected %d deletions, got %d", len(writers), len(deletions))
+	}
+}
+
+func TestJSONStoreSingleProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "npmclean.json")
+	s, err := OpenJSON(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := OpenJSON(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked for second open, got %v", err)
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "npmclean.db.lock")
+	l, err := newFileLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// Another goroutine holding it is waited for, not polled into a timeout
+	if err := l.Lock(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.Unlock()
+	}()
+	if err := l.Lock(time.Second); err != nil {
+		t.Fatalf("expected to get the lock once released, got %v", err)
+	}
+
+	// and a timeout doesn't blame another process
+	err = l.Lock(20 * time.Millisecond)
+	if !errors.Is(err, errBusy) || errors.Is(err, ErrLocked) {
+		t.Fatalf("expected a busy error, got %v", err)
+	}
+
+	// A second handle contends for the file lock itself
+	other, err := newFileLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	var locked *LockedError
+	if err := other.TryLock(); !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("expected the file lock held by pid %d, got %v", os.Getpid(), err)
+	}
+	l.Unlock()
+	if err := other.TryLock(); err != nil {
+		t.Fatalf("expected the lock once released, got %v", err)
+	}
+	other.Unlock()
+}
+
+func TestExportImport(t *testing.T) {
+	src := NewMemoryStore()
+	src.InsertOrUpdateBatch(testEntries(10))
+
+	var buf bytes.Buffer
+	if n, err := Export(src, &buf); err != nil || n != 10 {
+		t.Fatalf("export: %d %v", n, err)
+	}
+
+	dst := NewMemoryStore()
+	newer := &CacheEntry{Path: "/home/user/project-0/node_modules", Size: 1, ScannedAt: time.Now().Add(time.Hour)}
+	dst.InsertOrUpdate(newer)
+
+	res, err := Import(dst, &buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if res.Read != 10 || res.Inserted != 9 || res.Skipped != 1 {
+		t.Fatalf("unexpected import result: %+v", res)
+	}
+	if got, _ := dst.Get(newer.Path); got.Size != newer.Size {
+		t.Fatalf("newer entry was overwritten: %+v", got)
+	}
+}
+
+func TestImportRepeatedPaths(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range []*CacheEntry{
+		{Path: "/a/node_modules", Size: 1, ScannedAt: now},
+		{Path: "/a/node_modules", Size: 2, ScannedAt: now.Add(time.Hour)},
+		{Path: "/b/node_modules", Size: 3, ScannedAt: now.Add(time.Hour)},
+		{Path: "/b/node_modules", Size: 4, ScannedAt: now},
+		// Older than the store first, then newer
+		{Path: "/c/node_modules", Size: 5, ScannedAt: now.Add(-time.Hour)},
+		{Path: "/c/node_modules", Size: 6, ScannedAt: now.Add(time.Hour)},
+		{Path: "/d/node_modules", Size: 7, ScannedAt: now.Add(-time.Hour)},
+		{Path: "/d/node_modules", Size: 8, ScannedAt: now.Add(-2 * time.Hour)},
+	} {
+		enc.Encode(e)
+	}
+
+	for name, open := range testStores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			s.InsertOrUpdateBatch([]*CacheEntry{
+				{Path: "/c/node_modules", Size: 50, ScannedAt: now},
+				{Path: "/d/node_modules", Size: 70, ScannedAt: now},
+			})
+
+			res, err := Import(s, bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			// Every path is counted once, by what happened to it
+			want := ImportResult{Read: 8, Inserted: 2, Updated: 1, Skipped: 1}
+			if *res != want {
+				t.Fatalf("expected %+v, got %+v", want, *res)
+			}
+			for path, size := range map[string]int64{"/a/node_modules": 2, "/b/node_modules": 3, "/c/node_modules": 6, "/d/node_modules": 70} {
+				if got, err := s.Get(path); err != nil || got.Size != size {
+					t.Errorf("%s: expected size %d, got %+v %v", path, size, got, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	OutcomeDeleted = "deleted"
+	// Trashed trees still take up space until the trash is emptied
+	OutcomeTrashed = "trashed"
+	// Archived trees still take up their compressed size
+	OutcomeArchived = "archived"
+	// Relocated trees take up the same space in cold storage
+	OutcomeRelocated = "relocated"
+	// Quarantined trees are logged again as deleted once they are purged
+	OutcomeQuarantined = "quarantined"
+	OutcomeFailed      = "failed"
+	// Cancelled deletions have usually removed part of the tree already
+	OutcomeCancelled = "cancelled"
+)
+
+// DeletionRecord is a single row of the deletion audit log
+type DeletionRecord struct {
+	ID             int64     `json:"id"`
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	PackageManager string    `json:"package_manager"`
+	ProjectName    string    `json:"project_name"`
+	DeletedAt      time.Time `json:"deleted_at"`
+	Outcome        string    `json:"outcome"` // one of the Outcome constants
+	Error          string    `json:"error,omitempty"`
+}
+
+// DeletionTotal aggregates successful deletions of a single month
+type DeletionTotal struct {
+	Month string // YYYY-MM in local time
+	Count int64
+	Bytes int64
+}
+
+func (c *Cache) RecordDeletion(rec *DeletionRecord) error {
+	query := `
+        INSERT INTO deletions (path, size, package_manager, project_name, deleted_at, outcome, error)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `
+	return c.withWriteLock(func() error {
+		res, err := c.db.Exec(query, rec.Path, rec.Size, rec.PackageManager, rec.ProjectName, rec.DeletedAt.Unix(), rec.Outcome, rec.Error)
+		if err != nil {
+			return err
+		}
+		rec.ID, err = res.LastInsertId()
+		return err
+	})
+}
+
+// Deletions returns the audit log entries recorded at or after since, newest first
+func (c *Cache) Deletions(since time.Time) ([]*DeletionRecord, error) {
+	rows, err := c.db.Query(`
+        SELECT id, path, size, package_manager, project_name, deleted_at, outcome, error
+        FROM deletions WHERE deleted_at >= ? ORDER BY deleted_at DESC, id DESC
+    `, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*DeletionRecord
+	for rows.Next() {
+		var rec DeletionRecord
+		var deletedUnix int64
+		if err := rows.Scan(&rec.ID, &rec.Path, &rec.Size, &rec.PackageManager, &rec.ProjectName, &deletedUnix, &rec.Outcome, &rec.Error); err != nil {
+			return nil, err
+		}
+		rec.DeletedAt = time.Unix(deletedUnix, 0)
+		records = append(records, &rec)
+	}
+	return records, rows.Err()
+}
+
+// SummarizeDeletions groups successful deletions by month, newest month first
+func SummarizeDeletions(records []*DeletionRecord) []*DeletionTotal {
+	byMonth := make(map[string]*DeletionTotal)
+	for _, rec := range records {
+		if rec.Outcome != OutcomeDeleted {
+			continue
+		}
+		month := rec.DeletedAt.Local().Format("2006-01")
+		t, ok := byMonth[month]
+		if !ok {
+			t = &DeletionTotal{Month: month}
+			byMonth[month] = t
+		}
+		t.Count++
+		t.Bytes += rec.Size
+	}
+
+	totals := make([]*DeletionTotal, 0, len(byMonth))
+	for _, t := range byMonth {
+		totals = append(totals, t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Month > totals[j].Month })
+	return totals
+}
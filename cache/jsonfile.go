@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const jsonFileVersion = 1
+
+// JSONStore is a single file store for environments where SQLite files are
+// unwanted. Everything lives in memory and the file is rewritten atomically
+// on Flush, after audit records are added, and on Close. Only one process can
+// have the file open, others get a *LockedError from OpenJSON.
+type JSONStore struct {
+	*MemoryStore
+
+	path      string
+	lock      *fileLock
+	closeOnce sync.Once
+
+	// saveMu serialises writers of the file
+	saveMu sync.Mutex
+}
+
+var _ Store = (*JSONStore)(nil)
+
+type jsonFile struct {
+	Version    int              `json:"version"`
+	Entries    []CacheEntry     `json:"entries"`
+	Sessions   []Session        `json:"sessions"`
+	Deletions  []DeletionRecord `json:"deletions"`
+	Quarantine []QuarantineItem `json:"quarantine"`
+	Archives   []ArchiveItem    `json:"archives"`
+}
+
+// OpenJSON loads the store from path, a missing file is an empty store
+func OpenJSON(path string) (*JSONStore, error) {
+	lock, err := newFileLock(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+	if err := lock.TryLock(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	s := &JSONStore{MemoryStore: NewMemoryStore(), path: path, lock: lock}
+	if err := s.load(); err != nil {
+		s.release()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f jsonFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if f.Version != jsonFileVersion {
+		return fmt.Errorf("unsupported cache file version %d in %s", f.Version, s.path)
+	}
+
+	for _, entry := range f.Entries {
+		s.entries[entry.Path] = entry
+	}
+	s.sessions = f.Sessions
+	s.deletions = f.Deletions
+	s.quarantine = f.Quarantine
+	for _, item := range f.Quarantine {
+		s.quarantineSeq = max(s.quarantineSeq, item.ID)
+	}
+	s.archives = f.Archives
+	for _, item := range f.Archives {
+		s.archiveSeq = max(s.archiveSeq, item.ID)
+	}
+	return nil
+}
+
+func (s *JSONStore) release() {
+	s.lock.Unlock()
+	s.lock.Close()
+}
+
+func (s *JSONStore) FinishSession(session *Session) error {
+	if err := s.MemoryStore.FinishSession(session); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func (s *JSONStore) RecordDeletion(rec *DeletionRecord) error {
+	if err := s.MemoryStore.RecordDeletion(rec); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// Flush writes the current state to disk
+func (s *JSONStore) Flush() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.RLock()
+	f := jsonFile{
+		Version:    jsonFileVersion,
+		Entries:    make([]CacheEntry, 0, len(s.entries)),
+		Sessions:   s.sessions,
+		Deletions:  s.deletions,
+		Quarantine: s.quarantine,
+		Archives:   s.archives,
+	}
+	for _, entry := range s.entries {
+		f.Entries = append(f.Entries, entry)
+	}
+	data, err := json.Marshal(&f)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *JSONStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		defer s.release()
+		err = s.Flush()
+	})
+	return err
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Export writes every entry as JSON Lines, one CacheEntry per line in path order
+func Export(store Store, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	var n int
+	var encErr error
+	err := store.RangePrefix("", func(entry *CacheEntry) bool {
+		if encErr = enc.Encode(entry); encErr != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err == nil {
+		err = encErr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+type ImportResult struct {
+	Read     int
+	Inserted int
+	Updated  int
+	// Skipped entries were older than what the store already had
+	Skipped int
+}
+
+// Import reads JSON Lines written by Export and merges them into the store by
+// path, the entry with the newer ScannedAt wins. A path repeated in the file
+// is counted once, by what happened to the store.
+func Import(store Store, r io.Reader) (*ImportResult, error) {
+	res := &ImportResult{}
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	batch := make([]*CacheEntry, 0, DefaultBatchSize)
+	// Entries of the batch by path, a newer repeat replaces its entry
+	pending := make(map[string]*CacheEntry)
+	flush := func() error {
+		err := store.InsertOrUpdateBatch(batch)
+		batch = batch[:0]
+		clear(pending)
+		return err
+	}
+	queue := func(entry *CacheEntry) error {
+		if queued, ok := pending[entry.Path]; ok {
+			*queued = *entry
+			return nil
+		}
+		batch = append(batch, entry)
+		pending[entry.Path] = entry
+		if len(batch) == cap(batch) {
+			return flush()
+		}
+		return nil
+	}
+
+	// Every path counted so far, with the newest ScannedAt the store has for
+	// it once the import is done
+	type seenPath struct {
+		scannedAt time.Time
+		skipped   bool
+	}
+	seen := make(map[string]*seenPath)
+
+	for {
+		var entry CacheEntry
+		err := dec.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("entry %d: %w", res.Read+1, err)
+		}
+		res.Read++
+
+		if entry.Path == "" {
+			return res, fmt.Errorf("entry %d: missing path", res.Read)
+		}
+
+		if prev, ok := seen[entry.Path]; ok {
+			if !entry.ScannedAt.After(prev.scannedAt) {
+				continue
+			}
+			// Older than the store at first, this one updates it after all
+			if prev.skipped {
+				res.Skipped--
+				res.Updated++
+				prev.skipped = false
+			}
+			prev.scannedAt = entry.ScannedAt
+			if err := queue(&entry); err != nil {
+				return res, err
+			}
+			continue
+		}
+
+		existing, err := store.Get(entry.Path)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			res.Inserted++
+		case err != nil:
+			return res, err
+		case !entry.ScannedAt.After(existing.ScannedAt):
+			res.Skipped++
+			seen[entry.Path] = &seenPath{scannedAt: existing.ScannedAt, skipped: true}
+			continue
+		default:
+			res.Updated++
+		}
+		seen[entry.Path] = &seenPath{scannedAt: entry.ScannedAt}
+		if err := queue(&entry); err != nil {
+			return res, err
+		}
+	}
+	return res, flush()
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockTimeout is how long a write waits for another process holding the cache
+const LockTimeout = 10 * time.Second
+
+const lockPollInterval = 5 * time.Millisecond
+
+var ErrLocked = errors.New("cache: locked by another process")
+
+// errBusy is another goroutine of this process holding the lock
+var errBusy = errors.New("cache: busy with another write in this process")
+
+type LockedError struct {
+	Path string
+	PID  int // 0 if unknown
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("cache %s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("cache %s is locked by another process (pid %d)", e.Path, e.PID)
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// fileLock is an advisory, exclusive, cross process lock on a file next to the
+// cache. The holder writes its pid into the file so others can report it.
+type fileLock struct {
+	path string
+	f    *os.File
+
+	// sem serialises goroutines of this process, the OS lock is per file
+	// handle and would happily be acquired twice. A channel rather than a
+	// mutex so waiting for it can time out.
+	sem chan struct{}
+}
+
+func newFileLock(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	return &fileLock{path: path, f: f, sem: make(chan struct{}, 1)}, nil
+}
+
+// TryLock acquires the lock without waiting
+func (l *fileLock) TryLock() error {
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		return errBusy
+	}
+	return l.lockFile(time.Time{})
+}
+
+// Lock waits up to timeout for the lock, first for other goroutines of this
+// process and then for other processes
+func (l *fileLock) Lock(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+	case <-timer.C:
+		return fmt.Errorf("cache %s: timed out after %v: %w", l.path, timeout, errBusy)
+	}
+	return l.lockFile(deadline)
+}
+
+// lockFile takes the OS lock once the in process one is held, polling until
+// deadline while another process holds it. The in process lock is released
+// on failure.
+func (l *fileLock) lockFile(deadline time.Time) error {
+	for {
+		ok, err := tryLockFile(l.f)
+		if err != nil {
+			<-l.sem
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			<-l.sem
+			return &LockedError{Path: l.path, PID: l.holder()}
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	l.f.Truncate(0)
+	l.f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	return nil
+}
+
+func (l *fileLock) Unlock() error {
+	defer func() { <-l.sem }()
+	return unlockFile(l.f)
+}
+
+func (l *fileLock) Close() error {
+	return l.f.Close()
+}
+
+func (l *fileLock) holder() int {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"time"
+)
+
+// Vacuumer is implemented by stores that can reclaim unused space on disk
+type Vacuumer interface {
+	Vacuum() error
+}
+
+// DiskSizer is implemented by stores backed by files
+type DiskSizer interface {
+	// DiskSize is the total size of the files backing the store
+	DiskSize() (int64, error)
+}
+
+func (c *Cache) Vacuum() error {
+	return c.withWriteLock(func() error {
+		if _, err := c.db.Exec("VACUUM"); err != nil {
+			return err
+		}
+		// Fold the WAL back into the database so the file sizes reflect the vacuum
+		_, err := c.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+		return err
+	})
+}
+
+func (c *Cache) DiskSize() (int64, error) {
+	return filesSize(c.path, c.path+"-wal", c.path+"-shm")
+}
+
+func (s *JSONStore) Vacuum() error {
+	return s.Flush()
+}
+
+func (s *JSONStore) DiskSize() (int64, error) {
+	return filesSize(s.path)
+}
+
+func filesSize(paths ...string) (int64, error) {
+	var total int64
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+type Stats struct {
+	Entries    int64
+	TotalBytes int64
+	// Roots that have been scanned, most recent first
+	Roots     []string
+	Sessions  int64
+	Deletions int64
+	// DiskSize is -1 for stores that don't live on disk
+	DiskSize int64
+	Oldest   time.Time // oldest ScannedAt
+	Newest   time.Time // newest ScannedAt
+}
+
+func GetStats(store Store) (*Stats, error) {
+	entries, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Entries: int64(len(entries)), DiskSize: -1}
+	for _, e := range entries {
+		stats.TotalBytes += e.Size
+		if stats.Oldest.IsZero() || e.ScannedAt.Before(stats.Oldest) {
+			stats.Oldest = e.ScannedAt
+		}
+		if e.ScannedAt.After(stats.Newest) {
+			stats.Newest = e.ScannedAt
+		}
+	}
+
+	sessions, err := store.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	stats.Sessions = int64(len(sessions))
+	seen := make(map[string]bool)
+	for _, s := range sessions {
+		if !seen[s.Root] {
+			seen[s.Root] = true
+			stats.Roots = append(stats.Roots, s.Root)
+		}
+	}
+
+	deletions, err := store.Deletions(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	stats.Deletions = int64(len(deletions))
+
+	if ds, ok := store.(DiskSizer); ok {
+		if stats.DiskSize, err = ds.DiskSize(); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+type PruneOptions struct {
+	// Missing drops entries whose path no longer exists
+	Missing bool
+	// NotSeenFor drops entries not scanned within this duration, 0 disables it
+	NotSeenFor time.Duration
+	// DryRun only reports what would be dropped
+	DryRun bool
+}
+
+type PruneReason string
+
+const (
+	PruneMissing PruneReason = "missing"
+	PruneStale   PruneReason = "stale"
+)
+
+type PrunedEntry struct {
+	*CacheEntry
+	Reason PruneReason
+}
+
+// Prune removes entries matching opts and returns what was removed
+func Prune(store Store, opts PruneOptions) ([]*PrunedEntry, error) {
+	entries, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-opts.NotSeenFor)
+	var pruned []*PrunedEntry
+	for _, e := range entries {
+		var reason PruneReason
+		if opts.Missing {
+			if _, err := os.Lstat(e.Path); errors.Is(err, os.ErrNotExist) {
+				reason = PruneMissing
+			}
+		}
+		if reason == "" && opts.NotSeenFor > 0 && e.ScannedAt.Before(cutoff) {
+			reason = PruneStale
+		}
+		if reason == "" {
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := store.Delete(e.Path); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, &PrunedEntry{CacheEntry: e, Reason: reason})
+	}
+	return pruned, nil
+}
+
+// MeasureFunc computes the current size and modification time of a path
+type MeasureFunc func(path string) (size int64, modTime time.Time, err error)
+
+type Drift struct {
+	*CacheEntry
+	ActualSize    int64
+	ActualModTime time.Time
+	Missing       bool
+	Err           error
+}
+
+// Drifted reports whether the cached entry no longer matches the disk
+func (d *Drift) Drifted() bool {
+	return d.Missing || d.Err != nil || d.ActualSize != d.Size ||
+		!d.ActualModTime.Truncate(time.Second).Equal(d.LastModifiedAt.Truncate(time.Second))
+}
+
+// Verify re-measures a random sample of up to sample entries (all of them if
+// sample <= 0) and reports the result for each of them
+func Verify(store Store, sample int, measure MeasureFunc) ([]*Drift, error) {
+	entries, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if sample > 0 && sample < len(entries) {
+		rand.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+		entries = entries[:sample]
+	}
+
+	drifts := make([]*Drift, 0, len(entries))
+	for _, e := range entries {
+		d := &Drift{CacheEntry: e}
+		if _, err := os.Lstat(e.Path); errors.Is(err, os.ErrNotExist) {
+			d.Missing = true
+		} else {
+			d.ActualSize, d.ActualModTime, d.Err = measure(e.Path)
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in process memory. It backs --no-cache runs
+// and tests, and is the in-memory half of the JSON file store.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	entries   map[string]CacheEntry
+	sessions  []Session
+	deletions []DeletionRecord
+
+	quarantine    []QuarantineItem
+	quarantineSeq int64
+	archives      []ArchiveItem
+	archiveSeq    int64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]CacheEntry)}
+}
+
+func (m *MemoryStore) Get(path string) (*CacheEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
+
+func (m *MemoryStore) InsertOrUpdate(entry *CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.Path] = *entry
+	return nil
+}
+
+func (m *MemoryStore) InsertOrUpdateBatch(entries []*CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range entries {
+		m.entries[entry.Path] = *entry
+	}
+	return nil
+}
+
+func (m *MemoryStore) Delete(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, path)
+	return nil
+}
+
+func (m *MemoryStore) GetAll() ([]*CacheEntry, error) {
+	return m.collect(func(*CacheEntry) bool { return true }), nil
+}
+
+func (m *MemoryStore) RangePrefix(prefix string, fn func(*CacheEntry) bool) error {
+	entries := m.collect(func(e *CacheEntry) bool { return strings.HasPrefix(e.Path, prefix) })
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	// Iterate over copies without holding the lock, fn may write to the store
+	for _, entry := range entries {
+		if !fn(entry) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) collect(match func(*CacheEntry) bool) []*CacheEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var entries []*CacheEntry
+	for _, entry := range m.entries {
+		if match(&entry) {
+			entries = append(entries, &entry)
+		}
+	}
+	return entries
+}
+
+func (m *MemoryStore) StartSession(root string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session := Session{ID: int64(len(m.sessions)) + 1, Root: root, StartedAt: time.Now()}
+	m.sessions = append(m.sessions, session)
+	return &session, nil
+}
+
+func (m *MemoryStore) FinishSession(session *Session) error {
+	if session.FinishedAt.IsZero() {
+		session.FinishedAt = time.Now()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.sessions {
+		if m.sessions[i].ID == session.ID {
+			m.sessions[i] = *session
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) Sessions() ([]*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sessions := make([]*Session, 0, len(m.sessions))
+	for i := len(m.sessions) - 1; i >= 0; i-- {
+		s := m.sessions[i]
+		sessions = append(sessions, &s)
+	}
+	return sessions, nil
+}
+
+func (m *MemoryStore) RecordDeletion(rec *DeletionRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec.ID = int64(len(m.deletions)) + 1
+	m.deletions = append(m.deletions, *rec)
+	return nil
+}
+
+func (m *MemoryStore) Deletions(since time.Time) ([]*DeletionRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var records []*DeletionRecord
+	for i := len(m.deletions) - 1; i >= 0; i-- {
+		rec := m.deletions[i]
+		if rec.DeletedAt.Before(since) {
+			continue
+		}
+		records = append(records, &rec)
+	}
+	return records, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
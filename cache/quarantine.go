@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// QuarantineItem is a tree that was moved aside instead of being deleted, it
+// can be restored until it gets purged
+type QuarantineItem struct {
+	ID             int64     `json:"id"`
+	OriginalPath   string    `json:"original_path"`
+	StagedPath     string    `json:"staged_path"`
+	Size           int64     `json:"size"`
+	PackageManager string    `json:"package_manager"`
+	ProjectName    string    `json:"project_name"`
+	QuarantinedAt  time.Time `json:"quarantined_at"`
+}
+
+func (c *Cache) AddQuarantine(item *QuarantineItem) error {
+	query := `
+        INSERT INTO quarantine (original_path, staged_path, size, package_manager, project_name, quarantined_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `
+	return c.withWriteLock(func() error {
+		res, err := c.db.Exec(query, item.OriginalPath, item.StagedPath, item.Size, item.PackageManager, item.ProjectName, item.QuarantinedAt.Unix())
+		if err != nil {
+			return err
+		}
+		item.ID, err = res.LastInsertId()
+		return err
+	})
+}
+
+func (c *Cache) RemoveQuarantine(id int64) error {
+	return c.withWriteLock(func() error {
+		_, err := c.db.Exec("DELETE FROM quarantine WHERE id = ?", id)
+		return err
+	})
+}
+
+func (c *Cache) GetQuarantine(id int64) (*QuarantineItem, error) {
+	row := c.db.QueryRow(quarantineSelect+" WHERE id = ?", id)
+	item, err := scanQuarantine(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return item, err
+}
+
+func (c *Cache) Quarantined() ([]*QuarantineItem, error) {
+	rows, err := c.db.Query(quarantineSelect + " ORDER BY quarantined_at DESC, id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*QuarantineItem
+	for rows.Next() {
+		item, err := scanQuarantine(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+const quarantineSelect = `
+    SELECT id, original_path, staged_path, size, package_manager, project_name, quarantined_at
+    FROM quarantine`
+
+func scanQuarantine(row interface{ Scan(...any) error }) (*QuarantineItem, error) {
+	var item QuarantineItem
+	var quarantinedUnix int64
+	err := row.Scan(&item.ID, &item.OriginalPath, &item.StagedPath, &item.Size, &item.PackageManager, &item.ProjectName, &quarantinedUnix)
+	if err != nil {
+		return nil, err
+	}
+	item.QuarantinedAt = time.Unix(quarantinedUnix, 0)
+	return &item, nil
+}
+
+func (m *MemoryStore) AddQuarantine(item *QuarantineItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quarantineSeq++
+	item.ID = m.quarantineSeq
+	m.quarantine = append(m.quarantine, *item)
+	return nil
+}
+
+func (m *MemoryStore) RemoveQuarantine(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.quarantine {
+		if m.quarantine[i].ID == id {
+			m.quarantine = append(m.quarantine[:i], m.quarantine[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetQuarantine(id int64) (*QuarantineItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, item := range m.quarantine {
+		if item.ID == id {
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) Quarantined() ([]*QuarantineItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]*QuarantineItem, 0, len(m.quarantine))
+	for i := len(m.quarantine) - 1; i >= 0; i-- {
+		item := m.quarantine[i]
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+func (s *JSONStore) AddQuarantine(item *QuarantineItem) error {
+	if err := s.MemoryStore.AddQuarantine(item); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func (s *JSONStore) RemoveQuarantine(id int64) error {
+	if err := s.MemoryStore.RemoveQuarantine(id); err != nil {
+		return err
+	}
+	return s.Flush()
+}
@@ -0,0 +1,55 @@
+package cache
+
+import "time"
+
+func (c *Cache) StartSession(root string) (*Session, error) {
+	session := &Session{Root: root, StartedAt: time.Now()}
+	err := c.withWriteLock(func() error {
+		res, err := c.db.Exec("INSERT INTO sessions (root, started_at) VALUES (?, ?)", root, session.StartedAt.Unix())
+		if err != nil {
+			return err
+		}
+		session.ID, err = res.LastInsertId()
+		return err
+	})
+	return session, err
+}
+
+func (c *Cache) FinishSession(session *Session) error {
+	if session.FinishedAt.IsZero() {
+		session.FinishedAt = time.Now()
+	}
+	return c.withWriteLock(func() error {
+		_, err := c.db.Exec(`
+            UPDATE sessions SET finished_at = ?, entries = ?, file_count = ?, bytes = ?
+            WHERE id = ?
+        `, session.FinishedAt.Unix(), session.Entries, session.FileCount, session.Bytes, session.ID)
+		return err
+	})
+}
+
+func (c *Cache) Sessions() ([]*Session, error) {
+	rows, err := c.db.Query(`
+        SELECT id, root, started_at, finished_at, entries, file_count, bytes
+        FROM sessions ORDER BY started_at DESC, id DESC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var s Session
+		var startedUnix, finishedUnix int64
+		if err := rows.Scan(&s.ID, &s.Root, &startedUnix, &finishedUnix, &s.Entries, &s.FileCount, &s.Bytes); err != nil {
+			return nil, err
+		}
+		s.StartedAt = time.Unix(startedUnix, 0)
+		if finishedUnix != 0 {
+			s.FinishedAt = time.Unix(finishedUnix, 0)
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrNotFound = errors.New("cache: entry not found")
+
+// Store is the storage backend of the cache. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns ErrNotFound if there is no entry for path
+	Get(path string) (*CacheEntry, error)
+	InsertOrUpdate(entry *CacheEntry) error
+	InsertOrUpdateBatch(entries []*CacheEntry) error
+	Delete(path string) error
+	GetAll() ([]*CacheEntry, error)
+	// RangePrefix calls fn for every entry whose path starts with prefix in
+	// path order, until fn returns false. fn may modify the store.
+	RangePrefix(prefix string, fn func(*CacheEntry) bool) error
+
+	StartSession(root string) (*Session, error)
+	FinishSession(session *Session) error
+	// Sessions returns all recorded scan sessions, newest first
+	Sessions() ([]*Session, error)
+
+	RecordDeletion(rec *DeletionRecord) error
+	Deletions(since time.Time) ([]*DeletionRecord, error)
+
+	AddQuarantine(item *QuarantineItem) error
+	RemoveQuarantine(id int64) error
+	// GetQuarantine returns ErrNotFound if there is no item with id
+	GetQuarantine(id int64) (*QuarantineItem, error)
+	// Quarantined returns all quarantined items, newest first
+	Quarantined() ([]*QuarantineItem, error)
+
+	AddArchive(item *ArchiveItem) error
+	RemoveArchive(id int64) error
+	// GetArchive returns ErrNotFound if there is no item with id
+	GetArchive(id int64) (*ArchiveItem, error)
+	// Archives returns all archived trees, newest first
+	Archives() ([]*ArchiveItem, error)
+
+	Close() error
+}
+
+// Session is a single scan of a root directory
+type Session struct {
+	ID         int64     `json:"id"`
+	Root       string    `json:"root"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"` // zero while the scan is running or if it was aborted
+	Entries    int64     `json:"entries"`
+	FileCount  int64     `json:"file_count"`
+	Bytes      int64     `json:"bytes"`
+}
+
+const (
+	BackendSQLite = "sqlite"
+	BackendJSON   = "json"
+	BackendMemory = "memory"
+)
+
+var Backends = []string{BackendSQLite, BackendJSON, BackendMemory}
+
+// StorePath returns the file a backend keeps its data in inside dir, empty for
+// the memory backend
+func StorePath(backend, dir string) (string, error) {
+	switch backend {
+	case BackendSQLite, "":
+		return filepath.Join(dir, "npmclean.db"), nil
+	case BackendJSON:
+		return filepath.Join(dir, "npmclean.json"), nil
+	case BackendMemory:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown cache backend %q (want one of %v)", backend, Backends)
+	}
+}
+
+// OpenStore opens the named backend inside dir, see ResolveDir
+func OpenStore(backend, dir string) (Store, error) {
+	path, err := StorePath(backend, dir)
+	if err != nil {
+		return nil, err
+	}
+	if backend == BackendMemory {
+		return NewMemoryStore(), nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	if backend == BackendJSON {
+		return OpenJSON(path)
+	}
+	return Open(path)
+}
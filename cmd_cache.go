@@ -0,0 +1,332 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+var cacheCommands = map[string]*command{
+	"path":   {summary: "Print where the cache database lives", run: runCachePath},
+	"stats":  {summary: "Show what the cache tracks and how big it is", run: runCacheStats},
+	"prune":  {summary: "Drop entries that no longer exist or were not seen recently", run: runCachePrune},
+	"vacuum": {summary: "Reclaim unused space in the cache file", run: runCacheVacuum},
+	"verify": {summary: "Re-measure a sample of entries and report drift", run: runCacheVerify},
+	"export": {summary: "Write all entries as JSON Lines", run: runCacheExport},
+	"import": {summary: "Merge entries from a JSON Lines export", run: runCacheImport},
+}
+
+func runCache(args []string) int {
+	if len(args) == 0 {
+		printCacheCommands()
+		return 2
+	}
+	cmd, ok := cacheCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown cache command: %q\n", args[0])
+		printCacheCommands()
+		return 2
+	}
+	return cmd.run(args[1:])
+}
+
+func printCacheCommands() {
+	names := make([]string, 0, len(cacheCommands))
+	for name := range cacheCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: npmclean cache <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, cacheCommands[name].summary)
+	}
+}
+
+func runCachePath(args []string) int {
+	fs := flag.NewFlagSet("cache path", flag.ContinueOnError)
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	backend, dir, err := cf.resolve(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving cache directory: %v\n", err)
+		return 1
+	}
+	path, err := cache.StorePath(backend, dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "The memory backend does not store anything on disk")
+		return 1
+	}
+	fmt.Println(path)
+	return 0
+}
+
+// openCache parses the common flags of a cache subcommand and opens the store,
+// without a store code is the exit code
+func openCache(fs *flag.FlagSet, args []string) (cache.Store, int) {
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, 2
+	}
+	store, err := cf.open(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return nil, 1
+	}
+	return store, 0
+}
+
+func runCacheStats(args []string) int {
+	fs := flag.NewFlagSet("cache stats", flag.ContinueOnError)
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	stats, err := cache.GetStats(store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading cache: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Entries:\t%s\n", humanize.Comma(stats.Entries))
+	fmt.Fprintf(w, "Bytes tracked:\t%s\n", humanize.Bytes(uint64(stats.TotalBytes)))
+	if !stats.Oldest.IsZero() {
+		fmt.Fprintf(w, "Scanned:\t%s .. %s\n", humanize.Time(stats.Oldest), humanize.Time(stats.Newest))
+	}
+	fmt.Fprintf(w, "Scan sessions:\t%d\n", stats.Sessions)
+	fmt.Fprintf(w, "Deletions logged:\t%d\n", stats.Deletions)
+	if stats.DiskSize >= 0 {
+		fmt.Fprintf(w, "Database size:\t%s\n", humanize.Bytes(uint64(stats.DiskSize)))
+	}
+	fmt.Fprintf(w, "Roots:\t%d\n", len(stats.Roots))
+	for _, root := range stats.Roots {
+		fmt.Fprintf(w, "\t%s\n", root)
+	}
+	return 0
+}
+
+func runCachePrune(args []string) int {
+	fs := flag.NewFlagSet("cache prune", flag.ContinueOnError)
+	days := fs.Int("days", 0, "also drop entries not seen in this many days")
+	keepMissing := fs.Bool("keep-missing", false, "don't drop entries whose path no longer exists")
+	dryRun := fs.Bool("dry-run", false, "only list what would be dropped")
+	verbose := fs.Bool("v", false, "list every dropped entry")
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	opts := cache.PruneOptions{
+		Missing:    !*keepMissing,
+		NotSeenFor: time.Duration(*days) * 24 * time.Hour,
+		DryRun:     *dryRun,
+	}
+	pruned, err := cache.Prune(store, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pruning cache: %v\n", err)
+		return 1
+	}
+
+	var bytes int64
+	for _, p := range pruned {
+		bytes += p.Size
+		if *verbose || *dryRun {
+			fmt.Printf("%-8s %10s  %s\n", p.Reason, humanize.Bytes(uint64(p.Size)), p.Path)
+		}
+	}
+	verb := "Dropped"
+	if *dryRun {
+		verb = "Would drop"
+	}
+	fmt.Printf("%s %d entries tracking %s\n", verb, len(pruned), humanize.Bytes(uint64(bytes)))
+	return 0
+}
+
+func runCacheVacuum(args []string) int {
+	fs := flag.NewFlagSet("cache vacuum", flag.ContinueOnError)
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	v, ok := store.(cache.Vacuumer)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "This cache backend has nothing to vacuum")
+		return 1
+	}
+
+	var before int64 = -1
+	if ds, ok := store.(cache.DiskSizer); ok {
+		before, _ = ds.DiskSize()
+	}
+	if err := v.Vacuum(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error vacuuming cache: %v\n", err)
+		return 1
+	}
+	if ds, ok := store.(cache.DiskSizer); ok && before >= 0 {
+		after, _ := ds.DiskSize()
+		fmt.Printf("Cache size: %s -> %s\n", humanize.Bytes(uint64(before)), humanize.Bytes(uint64(after)))
+	}
+	return 0
+}
+
+func runCacheVerify(args []string) int {
+	fs := flag.NewFlagSet("cache verify", flag.ContinueOnError)
+	sample := fs.Int("sample", 20, "number of random entries to re-measure, 0 checks everything")
+	fix := fs.Bool("fix", false, "update drifted entries and drop missing ones")
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	drifts, err := cache.Verify(store, *sample, measureDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying cache: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tCACHED\tACTUAL\tPATH")
+	var drifted int
+	for _, d := range drifts {
+		if !d.Drifted() {
+			continue
+		}
+		drifted++
+		status, actual := "drift", humanize.Bytes(uint64(d.ActualSize))
+		switch {
+		case d.Missing:
+			status, actual = "missing", "-"
+		case d.Err != nil:
+			status, actual = "error", d.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, humanize.Bytes(uint64(d.Size)), actual, d.Path)
+
+		if *fix {
+			fixDrift(store, d)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("Checked %d entries, %d drifted\n", len(drifts), drifted)
+	if drifted > 0 && !*fix {
+		return 1
+	}
+	return 0
+}
+
+func measureDir(path string) (int64, time.Time, error) {
+	res, err := scanner.GetDirectorySize(path)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	modTime, err := scanner.GetLastModifiedAt(path)
+	return res.Size, modTime, err
+}
+
+func fixDrift(store cache.Store, d *cache.Drift) {
+	var err error
+	switch {
+	case d.Missing:
+		err = store.Delete(d.Path)
+	case d.Err == nil:
+		err = store.InsertOrUpdate(&cache.CacheEntry{
+			Path:           d.Path,
+			Size:           d.ActualSize,
+			LastModifiedAt: d.ActualModTime,
+			ScannedAt:      time.Now(),
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fixing %s: %v\n", d.Path, err)
+	}
+}
+
+func runCacheExport(args []string) int {
+	fs := flag.NewFlagSet("cache export", flag.ContinueOnError)
+	output := fs.String("o", "-", "file to write to, - for stdout")
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	w := os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *output, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := cache.Export(store, w)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting cache: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d entries\n", n)
+	return 0
+}
+
+func runCacheImport(args []string) int {
+	fs := flag.NewFlagSet("cache import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean cache import [flags] <file|->")
+		fs.PrintDefaults()
+	}
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	r := os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", name, err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	res, err := cache.Import(store, r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing cache: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Read %d entries: %d new, %d updated, %d skipped as older\n", res.Read, res.Inserted, res.Updated, res.Skipped)
+	return 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/riadafridishibly/npmclean/deleter"
+)
+
+func runClean(args []string) int {
+	fs := flag.NewFlagSet("clean", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean clean [flags] [path...]")
+		fmt.Fprintln(fs.Output(), "Scans every path (default the current directory) and deletes the trees passing the filters.")
+		fmt.Fprintln(fs.Output(), "Asks first, without a terminal -yes is required.")
+		fs.PrintDefaults()
+	}
+	sortBy := fs.String("sort", sortSize, fmt.Sprintf("order %v, the first ones are deleted with -limit", sortKeys))
+	limit := fs.Int("limit", 0, "only delete the first this many trees, 0 deletes all")
+	dryRun := fs.Bool("dry-run", false, "only report what would be deleted (default config dry_run)")
+	yes := fs.Bool("yes", false, "don't ask before deleting")
+	strategyName := fs.String("delete-strategy", "", fmt.Sprintf("how to delete %v (default config delete_strategy or remove)", deleter.Strategies))
+	ff := addFilterFlags(fs)
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !slices.Contains(sortKeys, *sortBy) {
+		fmt.Fprintf(os.Stderr, "Unknown sort order %q (want one of %v)\n", *sortBy, sortKeys)
+		return 2
+	}
+
+	cfg := loadConfig()
+	if *strategyName != "" {
+		cfg.DeleteStrategy = *strategyName
+	}
+	dry := *dryRun || cfg.DryRun
+	if !dry && !*yes && !isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "Refusing to delete without -yes when not run from a terminal")
+		return 2
+	}
+	roots, err := rootArgs(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	store, err := cf.open(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+	strategy, err := newStrategy(cf, cfg, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	modules, _, err := selectTrees(roots, store, ff)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if modules, err = sortTrees(modules, *sortBy, *limit); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if len(modules) == 0 {
+		fmt.Println("Nothing to clean")
+		return 0
+	}
+
+	guard := newGuard(cfg, roots...)
+	plan := deleter.NewPlan(strategy, guard, modules)
+	if dry || !*yes {
+		plan.WriteReport(os.Stdout, 0)
+	}
+	if dry || len(plan.Allowed()) == 0 {
+		return 0
+	}
+	if !*yes && !confirm("Proceed?") {
+		return 0
+	}
+
+	jobs, freed, measured := executePlan(store, strategy, guard, plan, cfg.DeleteWorkers, roots)
+	var count int
+	var bytes int64
+	for _, j := range jobs {
+		if j.State == deleter.JobDone {
+			count++
+			bytes += deleter.Estimate(strategy, j.Module)
+		}
+	}
+	fmt.Printf("Cleaned %d of %d items with %s, %s, %d blocked, %d failed\n",
+		count, len(modules), strategy.Name(), freedSummary(bytes, freed, measured), len(plan.Blocked()), len(plan.Allowed())-count)
+	if count < len(plan.Allowed()) {
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/cache"
+)
+
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	since := fs.Duration("since", 0, "only show deletions newer than this (e.g. 720h), 0 shows everything")
+	verbose := fs.Bool("v", false, "list every deletion instead of monthly totals only")
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	c, err := cf.open(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+
+	records, err := c.Deletions(from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading deletion history: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	if *verbose {
+		fmt.Fprintln(w, "DELETED AT\tOUTCOME\tSIZE\tPM\tPROJECT\tPATH")
+		for _, rec := range records {
+			outcome := rec.Outcome
+			if rec.Error != "" {
+				outcome += ": " + rec.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				rec.DeletedAt.Format("2006-01-02 15:04"), outcome, humanize.Bytes(uint64(rec.Size)),
+				rec.PackageManager, rec.ProjectName, rec.Path)
+		}
+		fmt.Fprintln(w)
+	}
+
+	var count, total int64
+	fmt.Fprintln(w, "MONTH\tDELETIONS\tRECLAIMED")
+	for _, t := range cache.SummarizeDeletions(records) {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", t.Month, t.Count, humanize.Bytes(uint64(t.Bytes)))
+		count += t.Count
+		total += t.Bytes
+	}
+	fmt.Fprintf(w, "Total\t%d\t%s\n", count, humanize.Bytes(uint64(total)))
+
+	return 0
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/report"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+const (
+	sortSize = "size"
+	sortAge  = "age"
+	sortPath = "path"
+)
+
+var sortKeys = []string{sortSize, sortAge, sortPath}
+
+const (
+	formatTable = "table"
+	formatPaths = "paths"
+)
+
+var listFormats = append([]string{formatTable, formatPaths}, report.Formats...)
+
+func runList(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean list [flags] [path...]")
+		fmt.Fprintln(fs.Output(), "Scans every path (default the current directory) and lists the trees passing the filters.")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", formatTable, fmt.Sprintf("output format %v", listFormats))
+	sortBy := fs.String("sort", "", fmt.Sprintf("order %v, largest, oldest or alphabetical first (default size, json and jsonl follow the scan unless given)", sortKeys))
+	limit := fs.Int("limit", 0, "only the first this many trees, 0 lists all")
+	output := fs.String("o", "-", "file to write to, - for stdout")
+	ff := addFilterFlags(fs)
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !slices.Contains(listFormats, *format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %q (want one of %v)\n", *format, listFormats)
+		return 2
+	}
+	if *sortBy != "" && !slices.Contains(sortKeys, *sortBy) {
+		fmt.Fprintf(os.Stderr, "Unknown sort order %q (want one of %v)\n", *sortBy, sortKeys)
+		return 2
+	}
+
+	roots, err := rootArgs(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	store, err := cf.open(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	out := os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *output, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if slices.Contains(report.Formats, *format) {
+		return listReport(out, roots, store, ff, *format, *sortBy, *limit)
+	}
+
+	modules, _, err := selectTrees(roots, store, ff)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if modules, err = sortTrees(modules, cmp.Or(*sortBy, sortSize), *limit); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if *format == formatPaths {
+		for _, module := range modules {
+			fmt.Fprintln(out, module.Path)
+		}
+		return 0
+	}
+	if err := writeTable(out, modules); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// listReport writes the trees passing the filters in one of the report
+// formats. Formats that stream get every tree as soon as it is found, unless
+// the trees have to be sorted or limited.
+func listReport(out io.Writer, roots []string, store cache.Store, ff *filterFlags, format, sortBy string, limit int) int {
+	rw, err := report.NewWriter(format, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	summary := report.Summary{Roots: roots}
+	var werr error
+	write := func(module *scanner.NodeModuleInfo) {
+		// Nobody is reading anymore
+		if werr != nil {
+			return
+		}
+		summary.Items++
+		summary.Size += module.Size
+		werr = rw.WriteItem(report.NewItem(module, rootOf(module.Path, roots)))
+	}
+
+	var sum scanSummary
+	if report.Streams(format) && sortBy == "" && limit == 0 {
+		sum, err = scanFiltered(roots, store, ff, write)
+	} else {
+		var modules []*scanner.NodeModuleInfo
+		if modules, sum, err = selectTrees(roots, store, ff); err == nil {
+			modules, _ = sortTrees(modules, cmp.Or(sortBy, sortSize), limit)
+			for _, module := range modules {
+				write(module)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	summary.ScannedItems = sum.Items
+	summary.ScannedSize = sum.Bytes
+	summary.Files = sum.Files
+	summary.Elapsed = sum.Elapsed
+	if werr == nil {
+		werr = rw.WriteSummary(summary)
+	}
+	if werr != nil {
+		fmt.Fprintf(os.Stderr, "Error writing results: %v\n", werr)
+		return 1
+	}
+	return 0
+}
+
+// sortTrees orders modules by key and keeps the first limit of them, all of
+// them when limit is 0
+func sortTrees(modules []*scanner.NodeModuleInfo, key string, limit int) ([]*scanner.NodeModuleInfo, error) {
+	switch key {
+	case sortSize:
+		slices.SortStableFunc(modules, func(a, b *scanner.NodeModuleInfo) int { return cmp.Compare(b.Size, a.Size) })
+	case sortAge:
+		slices.SortStableFunc(modules, func(a, b *scanner.NodeModuleInfo) int { return a.LastModifiedAt.Compare(b.LastModifiedAt) })
+	case sortPath:
+		slices.SortStableFunc(modules, func(a, b *scanner.NodeModuleInfo) int { return cmp.Compare(a.Path, b.Path) })
+	default:
+		return nil, fmt.Errorf("unknown sort order %q (want one of %v)", key, sortKeys)
+	}
+	if limit > 0 && len(modules) > limit {
+		modules = modules[:limit]
+	}
+	return modules, nil
+}
+
+func writeTable(out io.Writer, modules []*scanner.NodeModuleInfo) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tMODIFIED\tPM\tPROJECT\tPATH")
+	var total int64
+	for _, module := range modules {
+		info := project.Detect(module.Path)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			humanize.Bytes(uint64(module.Size)), humanize.Time(module.LastModifiedAt),
+			cmp.Or(string(info.PackageManager), "-"), info.Name, module.Path)
+		total += module.Size
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\n%d items, %s\n", len(modules), humanize.Bytes(uint64(total)))
+	return err
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/deleter"
+	"github.com/riadafridishibly/npmclean/policy"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func runPolicy(args []string) int {
+	fs := flag.NewFlagSet("policy", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean policy [flags] [path]")
+		fmt.Fprintln(fs.Output(), "Scans path (default the current directory) and deletes every tree a cleanup rule matches.")
+		fmt.Fprintln(fs.Output(), "Asks first, without a terminal -yes is required.")
+		fs.PrintDefaults()
+	}
+	dryRun := fs.Bool("dry-run", false, "only report what the rules match (default config dry_run)")
+	yes := fs.Bool("yes", false, "don't ask before deleting")
+	strategyName := fs.String("delete-strategy", "", fmt.Sprintf("how to delete %v (default config delete_strategy or remove)", deleter.Strategies))
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := loadConfig()
+	if *strategyName != "" {
+		cfg.DeleteStrategy = *strategyName
+	}
+	dry := *dryRun || cfg.DryRun
+	if !dry && !*yes && !isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "Refusing to delete without -yes when not run from a terminal")
+		return 2
+	}
+	p, err := policy.New(cfg.Rules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in cleanup rules: %v\n", err)
+		return 1
+	}
+	if p.Empty() {
+		fmt.Fprintln(os.Stderr, "No cleanup rules in the config file")
+		return 1
+	}
+	root, err := rootArg(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	store, err := cf.open(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+	strategy, err := newStrategy(cf, cfg, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	matches := p.Select(scanTrees(root, store), time.Now())
+	rules := make(map[string]string, len(matches))
+	modules := make([]*scanner.NodeModuleInfo, 0, len(matches))
+	for _, m := range matches {
+		rules[m.Module.Path] = m.Rule
+		modules = append(modules, m.Module)
+	}
+
+	guard := newGuard(cfg, root)
+	plan := deleter.NewPlan(strategy, guard, modules)
+	if dry || !*yes {
+		plan.WriteReport(os.Stdout, 0)
+	}
+	if dry || len(plan.Allowed()) == 0 {
+		return 0
+	}
+	if !*yes && !confirm("Proceed?") {
+		return 0
+	}
+	jobs, freed, measured := executePlan(store, strategy, guard, plan, cfg.DeleteWorkers, []string{root})
+
+	type total struct {
+		count int
+		bytes int64
+	}
+	perRule := make(map[string]*total)
+	var order []string
+	var done total
+	code := 0
+	for _, j := range jobs {
+		if j.State != deleter.JobDone {
+			code = 3
+			continue
+		}
+		rule := rules[j.Module.Path]
+		t, ok := perRule[rule]
+		if !ok {
+			t = &total{}
+			perRule[rule] = t
+			order = append(order, rule)
+		}
+		frees := deleter.Estimate(strategy, j.Module)
+		t.count++
+		t.bytes += frees
+		done.count++
+		done.bytes += frees
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tITEMS\tFREED")
+	for _, rule := range order {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", rule, perRule[rule].count, humanize.Bytes(uint64(perRule[rule].bytes)))
+	}
+	w.Flush()
+
+	fmt.Printf("\nCleaned %d of %d matching items with %s, %s, %d blocked, %d failed\n",
+		done.count, len(matches), strategy.Name(), freedSummary(done.bytes, freed, measured), len(plan.Blocked()), len(plan.Allowed())-done.count)
+	return code
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/config"
+	"github.com/riadafridishibly/npmclean/deleter"
+)
+
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean restore [flags] [id|a<id>|path ...]")
+		fmt.Fprintln(fs.Output(), "Without arguments the quarantined and archived items are listed.")
+		fs.PrintDefaults()
+	}
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+
+	items, err := store.Quarantined()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading quarantine: %v\n", err)
+		return 1
+	}
+	archives, err := store.Archives()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading archives: %v\n", err)
+		return 1
+	}
+
+	if fs.NArg() == 0 {
+		printQuarantine(items)
+		fmt.Println()
+		printArchives(archives)
+		return 0
+	}
+
+	for _, arg := range fs.Args() {
+		var path string
+		var err error
+		if item := findQuarantined(items, arg); item != nil {
+			path, err = item.OriginalPath, deleter.Restore(store, item)
+		} else if item := findArchived(archives, arg); item != nil {
+			path, err = item.OriginalPath, deleter.RestoreArchive(context.Background(), store, item)
+		} else {
+			fmt.Fprintf(os.Stderr, "Not quarantined or archived: %s\n", arg)
+			code = 1
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring %s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		fmt.Printf("Restored %s\n", path)
+	}
+	return code
+}
+
+func runPurge(args []string) int {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean purge [flags] [id|path ...]")
+		fmt.Fprintln(fs.Output(), "Without arguments items older than the quarantine TTL are purged.")
+		fs.PrintDefaults()
+	}
+	all := fs.Bool("all", false, "purge everything in quarantine")
+	dryRun := fs.Bool("dry-run", false, "only list what would be purged (default config dry_run)")
+	store, code := openCache(fs, args)
+	if store == nil {
+		return code
+	}
+	defer store.Close()
+	cfg := loadConfig()
+
+	var items []*cache.QuarantineItem
+	var err error
+	if *all || fs.NArg() > 0 {
+		items, err = store.Quarantined()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading quarantine: %v\n", err)
+			return 1
+		}
+		if !*all {
+			var selected []*cache.QuarantineItem
+			for _, arg := range fs.Args() {
+				if item := findQuarantined(items, arg); item != nil {
+					selected = append(selected, item)
+				} else {
+					fmt.Fprintf(os.Stderr, "Not quarantined: %s\n", arg)
+					code = 1
+				}
+			}
+			items = selected
+		}
+	} else {
+		items, err = deleter.Expired(store, quarantineTTL(cfg))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading quarantine: %v\n", err)
+			return 1
+		}
+	}
+
+	if *dryRun || cfg.DryRun {
+		printQuarantine(items)
+		fmt.Printf("Would purge %d items, reclaiming %s\n", len(items), humanize.Bytes(uint64(quarantineSize(items))))
+		return code
+	}
+
+	var purged []*cache.QuarantineItem
+	for _, item := range items {
+		if err := deleter.Purge(store, item); err != nil {
+			fmt.Fprintf(os.Stderr, "Error purging %s: %v\n", item.StagedPath, err)
+			code = 1
+			continue
+		}
+		purged = append(purged, item)
+	}
+	fmt.Printf("Purged %d items, reclaimed %s\n", len(purged), humanize.Bytes(uint64(quarantineSize(purged))))
+	return code
+}
+
+func quarantineSize(items []*cache.QuarantineItem) int64 {
+	var bytes int64
+	for _, item := range items {
+		bytes += item.Size
+	}
+	return bytes
+}
+
+func quarantineTTL(cfg *config.Config) time.Duration {
+	if cfg.QuarantineTTL > 0 {
+		return time.Duration(cfg.QuarantineTTL)
+	}
+	return deleter.DefaultQuarantineTTL
+}
+
+// findQuarantined looks an item up by id or original path, the newest wins
+// if a path was quarantined more than once
+func findQuarantined(items []*cache.QuarantineItem, arg string) *cache.QuarantineItem {
+	id, idErr := strconv.ParseInt(arg, 10, 64)
+	for _, item := range items {
+		if (idErr == nil && item.ID == id) || item.OriginalPath == arg {
+			return item
+		}
+	}
+	return nil
+}
+
+// findArchived looks an archive up by a<id> or original path, the newest wins
+// if a path was archived more than once
+func findArchived(items []*cache.ArchiveItem, arg string) *cache.ArchiveItem {
+	id := int64(-1)
+	if rest, ok := strings.CutPrefix(arg, "a"); ok {
+		if n, err := strconv.ParseInt(rest, 10, 64); err == nil {
+			id = n
+		}
+	}
+	for _, item := range items {
+		if item.ID == id || item.OriginalPath == arg {
+			return item
+		}
+	}
+	return nil
+}
+
+func printQuarantine(items []*cache.QuarantineItem) {
+	if len(items) == 0 {
+		fmt.Println("Quarantine is empty")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tQUARANTINED\tSIZE\tPATH")
+	for _, item := range items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", item.ID, humanize.Time(item.QuarantinedAt), humanize.Bytes(uint64(item.Size)), item.OriginalPath)
+	}
+}
+
+func printArchives(items []*cache.ArchiveItem) {
+	if len(items) == 0 {
+		fmt.Println("No archives")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ID\tARCHIVED\tSIZE\tARCHIVE\tPATH")
+	for _, item := range items {
+		fmt.Fprintf(w, "a%d\t%s\t%s\t%s\t%s\n", item.ID, humanize.Time(item.ArchivedAt), humanize.Bytes(uint64(item.Size)), humanize.Bytes(uint64(item.ArchiveSize)), item.OriginalPath)
+	}
+}
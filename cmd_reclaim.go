@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/deleter"
+)
+
+func runReclaim(args []string) int {
+	fs := flag.NewFlagSet("reclaim", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean reclaim -goal 20GB|+5GB [flags] [path]")
+		fmt.Fprintln(fs.Output(), "Scans path (default the current directory) and deletes trees until the goal is met,")
+		fmt.Fprintln(fs.Output(), "20GB means 20GB free on the filesystem of path, +5GB means 5GB more than now.")
+		fs.PrintDefaults()
+	}
+	goalText := fs.String("goal", "", "free space to reach, or with a leading + the amount to reclaim")
+	order := fs.String("order", deleter.OrderStalest, fmt.Sprintf("which trees go first %v", deleter.Orders))
+	dryRun := fs.Bool("dry-run", false, "only report the plan (default config dry_run)")
+	yes := fs.Bool("yes", false, "don't ask before deleting")
+	strategyName := fs.String("delete-strategy", "", fmt.Sprintf("how to delete %v (default config delete_strategy or remove)", deleter.Strategies))
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *goalText == "" {
+		fs.Usage()
+		return 2
+	}
+	if !slices.Contains(deleter.Orders, *order) {
+		fmt.Fprintf(os.Stderr, "Unknown order %q (want one of %v)\n", *order, deleter.Orders)
+		return 2
+	}
+	goal, err := deleter.ParseGoal(*goalText)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	cfg := loadConfig()
+	if *strategyName != "" {
+		cfg.DeleteStrategy = *strategyName
+	}
+	if !*dryRun && !cfg.DryRun && !*yes && !isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "Refusing to delete without -yes when not run from a terminal")
+		return 2
+	}
+	root, err := rootArg(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	need, err := goal.Need(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if need <= 0 {
+		fmt.Printf("Already met: %s\n", goal)
+		return 0
+	}
+
+	store, err := cf.open(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+	strategy, err := newStrategy(cf, cfg, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	guard := newGuard(cfg, root)
+	plan, err := deleter.NewTargetPlan(strategy, guard, scanTrees(root, store), need, *order)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	fmt.Printf("Goal: %s, %s to go\n\n", goal, humanize.Bytes(uint64(need)))
+	plan.WriteReport(os.Stdout, 0)
+	if *dryRun || cfg.DryRun {
+		return 0
+	}
+	if len(plan.Allowed()) == 0 {
+		fmt.Fprintf(os.Stderr, "Goal not met, no allowed tree frees space with %s\n", strategy.Name())
+		return 3
+	}
+	if !*yes && !confirm("Proceed?") {
+		return 0
+	}
+
+	jobs, freed, measured := executePlan(store, strategy, guard, plan, cfg.DeleteWorkers, []string{root})
+	var count int
+	var bytes int64
+	for _, j := range jobs {
+		if j.State == deleter.JobDone {
+			count++
+			bytes += deleter.Estimate(strategy, j.Module)
+		}
+	}
+	fmt.Printf("\nCleaned %d of %d planned items with %s, %s\n", count, len(plan.Allowed()), strategy.Name(), freedSummary(bytes, freed, measured))
+
+	if count < len(plan.Allowed()) {
+		return 3
+	}
+	// What the filesystem reports beats the estimates
+	short := plan.Short()
+	if measured {
+		short = max(need-freed, 0)
+	}
+	if short > 0 {
+		fmt.Fprintf(os.Stderr, "Goal not met, short by %s\n", humanize.Bytes(uint64(short)))
+		return 3
+	}
+	return 0
+}
+
+// confirm asks a yes or no question on the terminal, anything but yes is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Println()
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
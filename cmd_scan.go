@@ -0,0 +1,183 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func runScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean scan [flags] [path...]")
+		fmt.Fprintln(fs.Output(), "Scans every path (default the current directory), refreshes the cache and prints the totals.")
+		fs.PrintDefaults()
+	}
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	roots, err := rootArgs(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	store, err := cf.open(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	type total struct {
+		count int
+		bytes int64
+	}
+	perRoot := make(map[string]*total, len(roots))
+	for _, root := range roots {
+		perRoot[root] = &total{}
+	}
+	sum := scanRoots(roots, store, func(module *scanner.NodeModuleInfo) {
+		t := perRoot[rootOf(module.Path, roots)]
+		t.count++
+		t.bytes += module.Size
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROOT\tITEMS\tSIZE")
+	for _, root := range roots {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", root, perRoot[root].count, humanize.Bytes(uint64(perRoot[root].bytes)))
+	}
+	if len(roots) > 1 {
+		fmt.Fprintf(w, "Total\t%d\t%s\n", sum.Items, humanize.Bytes(uint64(sum.Bytes)))
+	}
+	w.Flush()
+	fmt.Printf("\nScanned %s files in %s\n", humanize.Comma(sum.Files), sum.Elapsed.Round(time.Millisecond))
+	return 0
+}
+
+// rootOf returns the innermost of roots path is under, trees found by a scan
+// always have one
+func rootOf(path string, roots []string) string {
+	var best string
+	for _, root := range roots {
+		if len(root) > len(best) && (path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(os.PathSeparator))+string(os.PathSeparator))) {
+			best = root
+		}
+	}
+	return best
+}
+
+// ageBucket groups trees last modified less than max ago
+type ageBucket struct {
+	name string
+	max  time.Duration
+}
+
+var ageBuckets = []ageBucket{
+	{"under 30 days", 30 * 24 * time.Hour},
+	{"30 to 90 days", 90 * 24 * time.Hour},
+	{"90 days to a year", 365 * 24 * time.Hour},
+	{"over a year", math.MaxInt64},
+}
+
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean stats [flags] [path...]")
+		fmt.Fprintln(fs.Output(), "Scans every path (default the current directory) and breaks the trees passing the filters down by package manager and age.")
+		fs.PrintDefaults()
+	}
+	top := fs.Int("top", 5, "how many of the largest trees to show")
+	ff := addFilterFlags(fs)
+	cf := addCacheFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	roots, err := rootArgs(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	store, err := cf.open(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening cache: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	modules, sum, err := selectTrees(roots, store, ff)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	type total struct {
+		count int
+		bytes int64
+	}
+	var all total
+	byPM := make(map[string]*total)
+	byAge := make([]total, len(ageBuckets))
+	now := time.Now()
+	for _, module := range modules {
+		all.count++
+		all.bytes += module.Size
+
+		pm := cmp.Or(string(project.Detect(module.Path).PackageManager), "unknown")
+		t, ok := byPM[pm]
+		if !ok {
+			t = &total{}
+			byPM[pm] = t
+		}
+		t.count++
+		t.bytes += module.Size
+
+		age := now.Sub(module.LastModifiedAt)
+		i := slices.IndexFunc(ageBuckets, func(b ageBucket) bool { return age < b.max })
+		byAge[i].count++
+		byAge[i].bytes += module.Size
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Trees:\t%d of %d scanned\n", all.count, sum.Items)
+	fmt.Fprintf(w, "Size:\t%s of %s scanned\n", humanize.Bytes(uint64(all.bytes)), humanize.Bytes(uint64(sum.Bytes)))
+	fmt.Fprintf(w, "Scan:\t%s files in %s\n", humanize.Comma(sum.Files), sum.Elapsed.Round(time.Millisecond))
+
+	pms := make([]string, 0, len(byPM))
+	for pm := range byPM {
+		pms = append(pms, pm)
+	}
+	slices.SortFunc(pms, func(a, b string) int { return cmp.Compare(byPM[b].bytes, byPM[a].bytes) })
+	fmt.Fprintln(w, "\nPACKAGE MANAGER\tITEMS\tSIZE")
+	for _, pm := range pms {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", pm, byPM[pm].count, humanize.Bytes(uint64(byPM[pm].bytes)))
+	}
+
+	fmt.Fprintln(w, "\nLAST MODIFIED\tITEMS\tSIZE")
+	for i, b := range ageBuckets {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", b.name, byAge[i].count, humanize.Bytes(uint64(byAge[i].bytes)))
+	}
+
+	if *top > 0 && len(modules) > 0 {
+		largest, _ := sortTrees(modules, sortSize, *top)
+		fmt.Fprintln(w, "\nLARGEST\tMODIFIED\tPATH")
+		for _, module := range largest {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", humanize.Bytes(uint64(module.Size)), humanize.Time(module.LastModifiedAt), module.Path)
+		}
+	}
+	w.Flush()
+	return 0
+}
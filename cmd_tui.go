@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/riadafridishibly/npmclean/deleter"
+	"github.com/riadafridishibly/npmclean/tui"
+)
+
+func runTUI(args []string) int {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: npmclean [tui] [flags] [path]")
+		fmt.Fprintln(fs.Output(), "Scans path (default the current directory) and browses the results interactively.")
+		fs.PrintDefaults()
+	}
+	cf := addCacheFlags(fs)
+	strategyName := fs.String("delete-strategy", "", fmt.Sprintf("how to delete %v (default config delete_strategy or remove)", deleter.Strategies))
+	dryRun := fs.Bool("dry-run", false, "report what would be deleted without touching anything (default config dry_run)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := loadConfig()
+	if *strategyName != "" {
+		cfg.DeleteStrategy = *strategyName
+	}
+	if *dryRun {
+		cfg.DryRun = true
+	}
+
+	absPath, err := rootArg(fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !isTerminal(os.Stdout) {
+		fmt.Fprintln(os.Stderr, "Error: the TUI needs a terminal, see npmclean help for the other commands")
+		return 1
+	}
+	fmt.Println("Logfile is being written in:", logPath())
+
+	store := openStore(cf, cfg)
+	defer store.Close()
+
+	strategy, err := newStrategy(cf, cfg, store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Deletions outlive a restart of the TUI and are waited for on exit
+	deletions := deleter.NewManager(strategy, newGuard(cfg, absPath), cfg.DeleteWorkers, nil)
+
+	// A kill cancels the deletions like the quit dialog does, so they stop
+	// between files and are recorded
+	var current atomic.Pointer[tui.App]
+	stop := onSignal(func() {
+		if app := current.Load(); app != nil {
+			app.Interrupt()
+		}
+		deletions.CancelAll()
+	})
+	defer stop()
+
+	for {
+		app := tui.NewApp(absPath, store, cfg, deletions)
+		current.Store(app)
+		if err := app.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running application: %v\n", err)
+			return 1
+		}
+		// Close scanner to flush pending cache writes
+		if app.Scanner() != nil {
+			app.Scanner().Close()
+		}
+		if app.ForceQuit() {
+			if n := deletions.Pending(); n > 0 {
+				fmt.Fprintf(os.Stderr, "Abandoned %d pending deletions\n", n)
+			}
+			return 0
+		}
+		if !app.ShouldRestart() {
+			break
+		}
+	}
+
+	if n := deletions.Pending(); n > 0 {
+		fmt.Printf("Waiting for %d pending deletions to finish...\n", n)
+	}
+	deletions.Close()
+	return 0
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/config"
+	"github.com/riadafridishibly/npmclean/deleter"
+	"github.com/riadafridishibly/npmclean/policy"
+	"github.com/riadafridishibly/npmclean/scanner"
+	"golang.org/x/term"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+// commands are the non-interactive subcommands, anything else given as the
+// first argument is treated as the directory to scan in the TUI
+var commands = map[string]*command{
+	"tui":     {summary: "Browse and delete interactively, the default without a command", run: runTUI},
+	"scan":    {summary: "Scan and refresh the cache, printing totals per root", run: runScan},
+	"list":    {summary: "List the trees passing the filters", run: runList},
+	"stats":   {summary: "Break the trees down by package manager and age", run: runStats},
+	"clean":   {summary: "Delete the trees passing the filters", run: runClean},
+	"history": {summary: "List past deletions and the space they reclaimed", run: runHistory},
+	"cache":   {summary: "Inspect and maintain the cache (path, stats, prune, vacuum, verify, export, import)", run: runCache},
+	"restore": {summary: "List quarantined or archived items or put them back in place", run: runRestore},
+	"purge":   {summary: "Permanently delete expired (or all) quarantined items", run: runPurge},
+	"policy":  {summary: "Delete everything the cleanup rules in the config file match", run: runPolicy},
+	"reclaim": {summary: "Delete the stalest or largest trees until enough space is free", run: runReclaim},
+}
+
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: npmclean [flags] [path] | npmclean <command> [flags] [path...]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun npmclean <command> -h for its flags.")
+	fmt.Fprintln(os.Stderr, "\nExit status:")
+	fmt.Fprintln(os.Stderr, "  0  success, including when there was nothing to do")
+	fmt.Fprintln(os.Stderr, "  1  an error stopped the command")
+	fmt.Fprintln(os.Stderr, "  2  invalid flags or arguments, or a deletion refused without -yes")
+	fmt.Fprintln(os.Stderr, "  3  some deletions failed or a goal was not met")
+}
+
+// loadConfig never fails, a broken config file is reported and ignored
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		fmt.Fprintf(os.Stderr, "Ignoring config file: %v\n", err)
+		return config.Default()
+	}
+	return cfg
+}
+
+// cacheFlags are shared by every command touching the cache
+type cacheFlags struct {
+	dir     string
+	backend string
+	noCache bool
+}
+
+func addCacheFlags(fs *flag.FlagSet) *cacheFlags {
+	f := &cacheFlags{}
+	fs.StringVar(&f.dir, "cache-dir", "", "cache directory (default $"+cache.EnvCacheDir+", config cache_dir or $XDG_CACHE_HOME/npmclean)")
+	fs.StringVar(&f.backend, "cache-backend", "", fmt.Sprintf("cache storage backend %v (default sqlite)", cache.Backends))
+	fs.BoolVar(&f.noCache, "no-cache", false, "keep everything in memory, nothing is read from or written to disk")
+	return f
+}
+
+func (f *cacheFlags) resolve(cfg *config.Config) (backend, dir string, err error) {
+	backend = f.backend
+	if backend == "" {
+		backend = cfg.CacheBackend
+	}
+	if f.noCache {
+		backend = cache.BackendMemory
+	}
+
+	override, err := config.ExpandHome(f.dir)
+	if err != nil {
+		return "", "", err
+	}
+	configured, err := config.ExpandHome(cfg.CacheDir)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err = cache.ResolveDir(override, configured)
+	return backend, dir, err
+}
+
+func (f *cacheFlags) open(cfg *config.Config) (cache.Store, error) {
+	backend, dir, err := f.resolve(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cache.OpenStore(backend, dir)
+}
+
+// newStrategy builds the configured deletion strategy, quarantined trees are
+// staged in the cache directory when it is on the same filesystem and
+// archives go next to the cache unless archive_dir says otherwise
+func newStrategy(cf *cacheFlags, cfg *config.Config, store cache.Store) (deleter.Strategy, error) {
+	_, dir, err := cf.resolve(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := store.(*cache.MemoryStore); ok {
+		switch cfg.DeleteStrategy {
+		case deleter.StrategyQuarantine, deleter.StrategyArchive:
+			return nil, fmt.Errorf("the %s strategy needs a persistent cache to remember what it moved", cfg.DeleteStrategy)
+		}
+	}
+
+	archiveDir := filepath.Join(dir, "archives")
+	if cfg.ArchiveDir != "" {
+		if archiveDir, err = config.AbsPath(cfg.ArchiveDir); err != nil {
+			return nil, err
+		}
+	}
+	return deleter.New(cfg.DeleteStrategy, deleter.Options{
+		Store:         store,
+		QuarantineDir: filepath.Join(dir, "quarantine"),
+		ArchiveDir:    archiveDir,
+		ArchiveFormat: cfg.ArchiveFormat,
+	})
+}
+
+// newGuard returns the safety checks for deleting under roots, including the
+// protected paths from the config
+func newGuard(cfg *config.Config, roots ...string) *deleter.Guard {
+	guard := deleter.NewGuard(roots...)
+	for _, p := range cfg.ProtectedPaths {
+		abs, err := config.AbsPath(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring protected path %s: %v\n", p, err)
+			continue
+		}
+		guard.Protect(abs)
+	}
+	return guard
+}
+
+// scanSummary adds up the scans of several roots
+type scanSummary struct {
+	Items   int
+	Bytes   int64
+	Files   int64
+	Elapsed time.Duration
+}
+
+// scanRoots scans every root in turn, reusing cached sizes that are still
+// valid and caching the new ones. found is called once for every tree as soon
+// as it is known, cached ones first.
+func scanRoots(roots []string, store cache.Store, found func(*scanner.NodeModuleInfo)) scanSummary {
+	var sum scanSummary
+	seen := make(map[string]bool)
+	report := func(module *scanner.NodeModuleInfo) {
+		// Nested roots find the same trees again
+		if seen[module.Path] {
+			return
+		}
+		seen[module.Path] = true
+		sum.Items++
+		sum.Bytes += module.Size
+		found(module)
+	}
+
+	for _, root := range roots {
+		s := scanner.NewScanner(root, store)
+		cached, err := s.LoadCachedResults()
+		if err != nil {
+			log.Printf("Failed to load cached results: %v", err)
+		}
+		for _, module := range cached {
+			report(module)
+		}
+		s.Start()
+		go func() {
+			for p := range s.Progress() {
+				if p.Error != nil {
+					log.Printf("Scan error: %v", p.Error)
+				}
+			}
+		}()
+		for module := range s.Results() {
+			report(module)
+		}
+		<-s.Done()
+		sum.Files += s.FileCount()
+		sum.Elapsed += s.ElapsedTime()
+		s.Close()
+	}
+	return sum
+}
+
+// scanTrees finds every node_modules under root
+func scanTrees(root string, store cache.Store) []*scanner.NodeModuleInfo {
+	var modules []*scanner.NodeModuleInfo
+	scanRoots([]string{root}, store, func(module *scanner.NodeModuleInfo) {
+		modules = append(modules, module)
+	})
+	return modules
+}
+
+// rootArg resolves the optional path argument of a command, the current
+// directory by default
+func rootArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() > 1 {
+		return "", fmt.Errorf("expected one path, got %d", fs.NArg())
+	}
+	roots, err := rootArgs(fs)
+	if err != nil {
+		return "", err
+	}
+	return roots[0], nil
+}
+
+// rootArgs resolves the path arguments of a command that scans several
+// roots, the current directory by default
+func rootArgs(fs *flag.FlagSet) ([]string, error) {
+	args := fs.Args()
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	roots := make([]string, 0, len(args))
+	for _, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, err
+		}
+		roots = append(roots, abs)
+	}
+	return roots, nil
+}
+
+// filterFlags select which trees a command works on, with the same
+// conditions a cleanup rule has
+type filterFlags struct {
+	rule config.Rule
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.Var(&f.rule.OlderThan, "older-than", "only trees not modified for at least this long (e.g. 30d)")
+	fs.Var(&f.rule.LargerThan, "larger-than", "only trees of at least this size (e.g. 500MB)")
+	fs.Var((*stringList)(&f.rule.Except), "exclude", "skip trees below this directory, repeatable")
+	return f
+}
+
+// policy turns the filters into a policy matching trees under roots
+func (f *filterFlags) policy(roots []string) (*policy.Policy, error) {
+	rule := f.rule
+	rule.Name = "filter"
+	rule.Under = roots
+	return policy.New([]config.Rule{rule})
+}
+
+// scanFiltered scans roots and calls found for every tree passing the
+// filters as soon as it is known
+func scanFiltered(roots []string, store cache.Store, f *filterFlags, found func(*scanner.NodeModuleInfo)) (scanSummary, error) {
+	p, err := f.policy(roots)
+	if err != nil {
+		return scanSummary{}, err
+	}
+	now := time.Now()
+	return scanRoots(roots, store, func(module *scanner.NodeModuleInfo) {
+		if p.Match(module, now) != "" {
+			found(module)
+		}
+	}), nil
+}
+
+// selectTrees scans roots and returns the trees passing the filters
+func selectTrees(roots []string, store cache.Store, f *filterFlags) ([]*scanner.NodeModuleInfo, scanSummary, error) {
+	var modules []*scanner.NodeModuleInfo
+	sum, err := scanFiltered(roots, store, f, func(module *scanner.NodeModuleInfo) {
+		modules = append(modules, module)
+	})
+	return modules, sum, err
+}
+
+// stringList is a flag that can be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// executePlan runs strategy on the allowed items of plan, records the
+// outcomes and reports failures on stderr. It returns the finished jobs and
+// how much the free space of the roots' filesystem grew, which is negative
+// when something else filled the disk meanwhile. measured is false when it
+// can't tell (roots on several filesystems can't be told apart).
+func executePlan(store cache.Store, strategy deleter.Strategy, guard *deleter.Guard, plan *deleter.Plan, workers int, roots []string) (jobs []deleter.Job, freed int64, measured bool) {
+	for _, item := range plan.Blocked() {
+		fmt.Fprintf(os.Stderr, "Skipping %s: %s\n", item.Module.Path, item.Blocked)
+	}
+
+	before, beforeErr := freeSpace(roots)
+	m := deleter.NewManager(strategy, guard, workers, nil)
+	for _, module := range plan.Allowed() {
+		m.Enqueue(module)
+	}
+	// Deletions stop between files and what was cancelled is still recorded
+	stop := onSignal(func() {
+		fmt.Fprintln(os.Stderr, "Interrupted, cancelling pending deletions...")
+		m.CancelAll()
+	})
+	m.Close()
+	stop()
+
+	jobs = m.Jobs()
+	for _, j := range jobs {
+		if err := deleter.Record(store, j.Module, strategy, j.Err); err != nil {
+			log.Printf("Failed to record deletion: %q: %v", j.Module.Path, err)
+		}
+		if j.State == deleter.JobDone {
+			store.Delete(j.Module.Path)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error deleting %s: %v\n", j.Module.Path, j.Err)
+		}
+	}
+
+	after, afterErr := freeSpace(roots)
+	if beforeErr != nil || afterErr != nil {
+		return jobs, 0, false
+	}
+	return jobs, after - before, true
+}
+
+func freeSpace(roots []string) (int64, error) {
+	if len(roots) != 1 {
+		return 0, errors.New("more than one root")
+	}
+	return deleter.FreeSpace(roots[0])
+}
+
+// onSignal calls fn on the first SIGINT or SIGTERM, a second one kills the
+// process as usual. stop undoes it.
+func onSignal(fn func()) (stop func()) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+			fn()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		cancel()
+	}
+}
+
+// freedSummary puts what was actually freed next to the estimate, leaving it
+// out when it wasn't measured
+func freedSummary(estimated, freed int64, measured bool) string {
+	s := "estimated " + humanize.Bytes(uint64(estimated))
+	if measured {
+		s += ", actually freed " + deleter.SignedBytes(freed)
+	}
+	return s
+}
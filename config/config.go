@@ -0,0 +1,338 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// EnvConfigPath overrides the location of the config file
+const EnvConfigPath = "NPMCLEAN_CONFIG"
+
+// Confirmation policies
+const (
+	ConfirmAlways = "always"
+	// ConfirmLarge only asks when a deletion frees at least ConfirmAbove, for
+	// a batch of selected trees that is their total
+	ConfirmLarge = "large"
+	ConfirmNever = "never"
+)
+
+// DefaultConfirmAbove is the ConfirmLarge threshold when none is configured
+const DefaultConfirmAbove = 1_000_000_000
+
+type Config struct {
+	ReplaceHomeWithTilde bool          `json:"replace_home_with_tilde"`
+	ProgressUpdateFreq   time.Duration `json:"progress_update_freq"`
+
+	// CacheDir is where the cache database lives, empty means the XDG default
+	CacheDir string `json:"cache_dir,omitempty"`
+	// CacheBackend is one of cache.Backends, empty means sqlite
+	CacheBackend string `json:"cache_backend,omitempty"`
+
+	// DeleteStrategy is one of deleter.Strategies, empty means remove
+	DeleteStrategy string `json:"delete_strategy,omitempty"`
+	// QuarantineTTL is how long quarantined trees are kept before they are
+	// purged, zero means the default of 7 days
+	QuarantineTTL Duration `json:"quarantine_ttl,omitempty"`
+	// ArchiveDir is where the archive strategy stores its tarballs, empty
+	// means the archives directory next to the cache
+	ArchiveDir string `json:"archive_dir,omitempty"`
+	// ArchiveFormat is "zst" or "gz", empty means zst
+	ArchiveFormat string `json:"archive_format,omitempty"`
+	// DeleteWorkers is how many trees are deleted at the same time, zero means
+	// the default of 4
+	DeleteWorkers int `json:"delete_workers,omitempty"`
+	// DryRun reports what destructive actions would do without doing them
+	DryRun bool `json:"dry_run,omitempty"`
+	// Confirm is when deletions are confirmed, one of the Confirm* policies,
+	// empty means ConfirmAlways
+	Confirm string `json:"confirm,omitempty"`
+	// ConfirmAbove is the size from which ConfirmLarge asks, compared to the
+	// total of the batch rather than each tree, zero means the default of 1 GB
+	ConfirmAbove Size `json:"confirm_above,omitempty"`
+
+	// ColdStorageDir is where trees are moved to make room, leaving a symlink
+	// behind. Moving is disabled when it is empty.
+	ColdStorageDir string `json:"cold_storage_dir,omitempty"`
+
+	// Rules select trees for cleanup, a tree matching any of them is
+	// highlighted in the TUI and deleted when the policy is applied
+	Rules []Rule `json:"rules,omitempty"`
+
+	// ProtectedPaths are project directories nothing is ever deleted from
+	ProtectedPaths []string `json:"protected_paths,omitempty"`
+
+	// InstallCommand replaces the package manager's install command when
+	// reinstalling, e.g. ["sh", "-c", "echo offline"] for testing
+	InstallCommand []string `json:"install_command,omitempty"`
+
+	// path the config was loaded from, Save writes back to it
+	path string
+}
+
+// Path returns the config file location: $NPMCLEAN_CONFIG, or
+// $XDG_CONFIG_HOME/npmclean/config.json falling back to ~/.config
+func Path() (string, error) {
+	if p := os.Getenv(EnvConfigPath); p != "" {
+		return ExpandHome(p)
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "npmclean", "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "npmclean", "config.json"), nil
+}
+
+func Default() *Config {
+	return &Config{
+		ReplaceHomeWithTilde: true,
+		ProgressUpdateFreq:   150 * time.Millisecond,
+	}
+}
+
+// Load reads the config file, a missing file yields the defaults
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	cfg.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes the config back to the file it was loaded from
+func (c *Config) Save() error {
+	if c.path == "" {
+		path, err := Path()
+		if err != nil {
+			return err
+		}
+		c.path = path
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// A temporary file of its own, so concurrent saves can't write into each
+	// other's before the rename
+	f, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Chmod(0o644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path)
+}
+
+// updateMu serialises Update, each one reads the file the previous one wrote
+var updateMu sync.Mutex
+
+// Update applies fn to the config file as it is on disk and saves it, leaving
+// out any overrides made in memory, like command line flags
+func Update(fn func(*Config)) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	fn(cfg)
+	return cfg.Save()
+}
+
+// Rule selects trees for cleanup, every condition that is set must hold
+type Rule struct {
+	// Name shows up in reports, empty means a description of the conditions
+	Name string `json:"name,omitempty"`
+	// OlderThan matches trees not modified for at least this long
+	OlderThan Duration `json:"older_than,omitempty"`
+	// LargerThan matches trees of at least this size
+	LargerThan Size `json:"larger_than,omitempty"`
+	// Under limits the rule to trees below these directories
+	Under []string `json:"under,omitempty"`
+	// Except skips trees below these directories
+	Except []string `json:"except,omitempty"`
+}
+
+// Duration is a time.Duration written as a string in the config file, with
+// "d" accepted for days on top of the units time.ParseDuration knows
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(formatDuration(time.Duration(d)))
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// Plain numbers are nanoseconds, like encoding time.Duration directly
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// String and Set make Duration a flag.Value
+func (d *Duration) String() string {
+	return formatDuration(time.Duration(*d))
+}
+
+func (d *Duration) Set(s string) error {
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// formatDuration writes whole days the way they are usually typed, "60d"
+// rather than "1440h0m0s"
+func formatDuration(d time.Duration) string {
+	if day := 24 * time.Hour; d != 0 && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+// ParseDuration is time.ParseDuration that also understands whole days ("7d")
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// AbsPath expands a leading ~ and makes p absolute
+func AbsPath(p string) (string, error) {
+	p, err := ExpandHome(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(p)
+}
+
+// ExpandHome replaces a leading ~ with the user's home directory
+func ExpandHome(p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, p[1:]), nil
+}
+
+// Size is a byte count written as a string in the config file, like "2 GB"
+type Size int64
+
+func (s Size) MarshalJSON() ([]byte, error) {
+	return json.Marshal(formatSize(int64(s)))
+}
+
+// sizeUnits are tried largest first, decimal before binary
+var sizeUnits = []struct {
+	name string
+	n    int64
+}{
+	{"TB", humanize.TByte}, {"TiB", humanize.TiByte},
+	{"GB", humanize.GByte}, {"GiB", humanize.GiByte},
+	{"MB", humanize.MByte}, {"MiB", humanize.MiByte},
+	{"kB", humanize.KByte}, {"KiB", humanize.KiByte},
+}
+
+// formatSize writes n in the largest unit it is a whole multiple of, unlike
+// humanize.Bytes it never rounds so the config file keeps what was typed
+func formatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n != 0 && n%u.n == 0 {
+			return fmt.Sprintf("%d %s", n/u.n, u.name)
+		}
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		// Plain numbers are bytes
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid size %s", data)
+		}
+		*s = Size(n)
+		return nil
+	}
+	n, err := humanize.ParseBytes(str)
+	if err != nil {
+		return fmt.Errorf("invalid size %q: %w", str, err)
+	}
+	*s = Size(n)
+	return nil
+}
+
+// String and Set make Size a flag.Value
+func (s *Size) String() string {
+	return formatSize(int64(*s))
+}
+
+func (s *Size) Set(str string) error {
+	n, err := humanize.ParseBytes(str)
+	if err != nil {
+		return fmt.Errorf("invalid size %q: %w", str, err)
+	}
+	*s = Size(n)
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSizeRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		size Size
+		want string
+	}{
+		{0, `"0 B"`},
+		{1500000123, `"1500000123 B"`},
+		{1_500_000_000, `"1500 MB"`},
+		{2 << 30, `"2 GiB"`},
+		{200_000_000, `"200 MB"`},
+		{1000, `"1 kB"`},
+		{1024, `"1 KiB"`},
+	} {
+		data, err := json.Marshal(tc.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("%d: got %s, want %s", tc.size, data, tc.want)
+		}
+		var got Size
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.size {
+			t.Errorf("%s: got %d back, want %d", data, got, tc.size)
+		}
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, `"0s"`},
+		{60 * 24 * time.Hour, `"60d"`},
+		{36 * time.Hour, `"36h0m0s"`},
+		{90 * time.Minute, `"1h30m0s"`},
+		{1500 * time.Millisecond, `"1.5s"`},
+	} {
+		data, err := json.Marshal(Duration(tc.d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.d, data, tc.want)
+		}
+		var got Duration
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if time.Duration(got) != tc.d {
+			t.Errorf("%s: got %v back, want %v", data, time.Duration(got), tc.d)
+		}
+	}
+}
+
+// Saving the config must not rewrite what the user typed
+func TestRuleRoundTrip(t *testing.T) {
+	in := `{"name":"stale","older_than":"60d","larger_than":"1500000123 B"}`
+	var r Rule
+	if err := json.Unmarshal([]byte(in), &r); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("got %s, want %s", out, in)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvConfigPath, filepath.Join(dir, "config.json"))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update(func(c *Config) {
+				c.ProtectedPaths = append(c.ProtectedPaths, fmt.Sprintf("/p/%d", i))
+			})
+			if err != nil {
+				t.Errorf("update %d: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 20 {
+		if p := fmt.Sprintf("/p/%d", i); !slices.Contains(cfg.ProtectedPaths, p) {
+			t.Errorf("update of %s was lost: %v", p, cfg.ProtectedPaths)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the config file, got %v", entries)
+	}
+}
@@ -0,0 +1,123 @@
+package deleter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/archive"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// Archiver packs trees into a compressed tarball before removing them, and
+// records the archive in the cache so it can be unpacked back in place
+type Archiver struct {
+	store  cache.Store
+	dir    string
+	format string
+
+	mu sync.Mutex
+	// Archives of trees whose removal failed or was cancelled, by path. Only
+	// the archive has all of such a tree, a retry removes the rest instead of
+	// packing what is left.
+	partial map[string]int64
+}
+
+// NewArchiver stores archives in dir, format is one of archive.Formats and
+// empty means zstd
+func NewArchiver(store cache.Store, dir, format string) (*Archiver, error) {
+	switch format {
+	case "":
+		format = archive.FormatZstd
+	case archive.FormatZstd, archive.FormatGzip:
+	default:
+		return nil, fmt.Errorf("unknown archive format %q (want one of %v)", format, archive.Formats)
+	}
+	return &Archiver{store: store, dir: dir, format: format, partial: make(map[string]int64)}, nil
+}
+
+func (a *Archiver) Name() string {
+	return StrategyArchive
+}
+
+func (a *Archiver) Delete(module *scanner.NodeModuleInfo) error {
+	return a.DeleteContext(context.Background(), module, nil)
+}
+
+func (a *Archiver) DeleteContext(ctx context.Context, module *scanner.NodeModuleInfo, progress ProgressFunc) error {
+	a.mu.Lock()
+	id, partial := a.partial[module.Path]
+	a.mu.Unlock()
+	if partial {
+		// Unless the archive was restored or removed in the meantime
+		if item, err := a.store.GetArchive(id); err == nil {
+			if _, err := os.Stat(item.ArchivePath); err == nil {
+				return a.remove(ctx, module.Path, id, progress)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(a.dir, 0o700); err != nil {
+		return fmt.Errorf("no usable archive directory: %w", err)
+	}
+
+	proj := project.Detect(module.Path)
+	name := fmt.Sprintf("%d-%s%s", time.Now().UnixNano(), strings.ReplaceAll(proj.Name, "/", "_"), archive.Ext(a.format))
+	dst := filepath.Join(a.dir, name)
+	size, err := archive.Create(ctx, module.Path, dst, a.format)
+	if err != nil {
+		return fmt.Errorf("failed to archive: %w", err)
+	}
+
+	item := &cache.ArchiveItem{
+		OriginalPath:   module.Path,
+		ArchivePath:    dst,
+		Size:           module.Size,
+		ArchiveSize:    size,
+		PackageManager: string(proj.PackageManager),
+		ProjectName:    proj.Name,
+		ArchivedAt:     time.Now(),
+	}
+	if err := a.store.AddArchive(item); err != nil {
+		// An archive nobody knows about is just wasted space
+		os.Remove(dst)
+		return fmt.Errorf("failed to record archive: %w", err)
+	}
+
+	return a.remove(ctx, module.Path, item.ID, progress)
+}
+
+// remove deletes the tree at path, which is safe in archive id
+func (a *Archiver) remove(ctx context.Context, path string, id int64, progress ProgressFunc) error {
+	err := removeTree(ctx, path, progress)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.partial[path] = id
+		return fmt.Errorf("%w (%s is incomplete now, archive a%d has all of it and a retry removes the rest)", err, path, id)
+	}
+	delete(a.partial, path)
+	return nil
+}
+
+// RestoreArchive unpacks an archived tree back where it came from and drops
+// the archive
+func RestoreArchive(ctx context.Context, store cache.Store, item *cache.ArchiveItem) error {
+	if _, err := os.Lstat(item.OriginalPath); err == nil {
+		return fmt.Errorf("%s already exists", item.OriginalPath)
+	}
+	if err := archive.Extract(ctx, item.ArchivePath, item.OriginalPath); err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+	if err := os.Remove(item.ArchivePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return store.RemoveArchive(item.ID)
+}
@@ -0,0 +1,136 @@
+package deleter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riadafridishibly/npmclean/archive"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestArchiveRestore(t *testing.T) {
+	for _, format := range archive.Formats {
+		t.Run(format, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "app", "node_modules")
+			bin := filepath.Join(path, ".bin")
+			pkg := filepath.Join(path, "left-pad")
+			for _, dir := range []string{bin, pkg} {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(pkg, "cli.js"), []byte("#!/usr/bin/env node\n"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(pkg, "index.js"), []byte("module.exports = {}\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("../left-pad/cli.js", filepath.Join(bin, "left-pad")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Link(filepath.Join(pkg, "index.js"), filepath.Join(pkg, "main.js")); err != nil {
+				t.Fatal(err)
+			}
+
+			store := cache.NewMemoryStore()
+			a, err := NewArchiver(store, filepath.Join(root, "archives"), format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Delete(&scanner.NodeModuleInfo{Path: path, Size: 4096}); err != nil {
+				t.Fatalf("archive: %v", err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Fatalf("expected %s to be gone, got %v", path, err)
+			}
+
+			items, _ := store.Archives()
+			if len(items) != 1 || items[0].OriginalPath != path || items[0].ArchiveSize == 0 || !strings.HasSuffix(items[0].ArchivePath, archive.Ext(format)) {
+				t.Fatalf("unexpected archives: %+v", items)
+			}
+			if err := RestoreArchive(context.Background(), store, items[0]); err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+
+			if info, err := os.Stat(filepath.Join(pkg, "cli.js")); err != nil || info.Mode().Perm() != 0o755 {
+				t.Fatalf("executable not restored: %v %v", info, err)
+			}
+			if info, err := os.Stat(filepath.Join(pkg, "index.js")); err != nil || info.Mode().Perm() != 0o600 {
+				t.Fatalf("private file not restored: %v %v", info, err)
+			}
+			if target, err := os.Readlink(filepath.Join(bin, "left-pad")); err != nil || target != "../left-pad/cli.js" {
+				t.Fatalf("symlink not restored: %q %v", target, err)
+			}
+			a1, _ := os.Stat(filepath.Join(pkg, "index.js"))
+			a2, _ := os.Stat(filepath.Join(pkg, "main.js"))
+			if !os.SameFile(a1, a2) {
+				t.Fatal("hardlink not restored")
+			}
+
+			if items, _ := store.Archives(); len(items) != 0 {
+				t.Fatalf("archive still recorded: %+v", items)
+			}
+			if entries, _ := os.ReadDir(filepath.Join(root, "archives")); len(entries) != 0 {
+				t.Fatalf("archive directory not empty: %v", entries)
+			}
+		})
+	}
+}
+
+func TestArchiveRetryAfterCancel(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app", "node_modules")
+	for i := range 5 {
+		dir := filepath.Join(path, fmt.Sprintf("pkg%d", i))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("module.exports = {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := cache.NewMemoryStore()
+	a, err := NewArchiver(store, filepath.Join(root, "archives"), archive.FormatZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := &scanner.NodeModuleInfo{Path: path, Size: 4096}
+
+	// Cancelled after the first file is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	err = a.DeleteContext(ctx, module, func(files, bytes int64) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled removal, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected a partial tree, got %v", err)
+	}
+
+	if err := a.Delete(module); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	items, _ := store.Archives()
+	if len(items) != 1 {
+		t.Fatalf("expected the retry to reuse the archive, got %d archives", len(items))
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "archives")); len(entries) != 1 {
+		t.Fatalf("expected one archive file, got %v", entries)
+	}
+
+	if err := RestoreArchive(context.Background(), store, items[0]); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	for i := range 5 {
+		if _, err := os.Stat(filepath.Join(path, fmt.Sprintf("pkg%d", i), "index.js")); err != nil {
+			t.Errorf("restored tree is incomplete: %v", err)
+		}
+	}
+}
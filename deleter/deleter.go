@@ -0,0 +1,99 @@
+package deleter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+const (
+	StrategyRemove     = "remove"
+	StrategyTrash      = "trash"
+	StrategyQuarantine = "quarantine"
+	StrategyArchive    = "archive"
+)
+
+var Strategies = []string{StrategyRemove, StrategyTrash, StrategyQuarantine, StrategyArchive}
+
+var ErrUnsupported = errors.New("deletion strategy not supported on this platform")
+
+// Strategy is how a directory tree gets out of the way
+type Strategy interface {
+	Name() string
+	Delete(module *scanner.NodeModuleInfo) error
+}
+
+// Options configure the strategies that keep track of what they moved
+type Options struct {
+	// Store records quarantined and archived trees
+	Store cache.Store
+	// QuarantineDir is the preferred quarantine staging area
+	QuarantineDir string
+	// ArchiveDir is where archives are written
+	ArchiveDir string
+	// ArchiveFormat is one of archive.Formats, empty means zstd
+	ArchiveFormat string
+}
+
+// New returns the named strategy, empty means StrategyRemove
+func New(name string, opts Options) (Strategy, error) {
+	switch name {
+	case StrategyRemove, "":
+		return Remove{}, nil
+	case StrategyTrash:
+		return NewTrash()
+	case StrategyQuarantine:
+		return NewQuarantine(opts.Store, opts.QuarantineDir), nil
+	case StrategyArchive:
+		return NewArchiver(opts.Store, opts.ArchiveDir, opts.ArchiveFormat)
+	default:
+		return nil, fmt.Errorf("unknown deletion strategy %q (want one of %v)", name, Strategies)
+	}
+}
+
+// Verb describes what the strategy does to a tree, for confirmations and buttons
+func Verb(s Strategy) string {
+	switch s.Name() {
+	case StrategyTrash:
+		return "Move to Trash"
+	case StrategyQuarantine:
+		return "Quarantine"
+	case StrategyArchive:
+		return "Archive"
+	case StrategyRelocate:
+		return "Move to cold storage"
+	default:
+		return "Delete"
+	}
+}
+
+// Estimate is how much space running s on module is expected to free.
+// Trashed and quarantined trees stay on their filesystem and free nothing,
+// archives still take up their compressed size, which isn't known up front.
+func Estimate(s Strategy, module *scanner.NodeModuleInfo) int64 {
+	switch s := s.(type) {
+	case *Trash, *Quarantine:
+		return 0
+	case *Relocator:
+		return s.Frees(module)
+	}
+	return module.Size
+}
+
+// Remove deletes the tree for good
+type Remove struct{}
+
+func (Remove) Name() string {
+	return StrategyRemove
+}
+
+func (r Remove) Delete(module *scanner.NodeModuleInfo) error {
+	return r.DeleteContext(context.Background(), module, nil)
+}
+
+func (Remove) DeleteContext(ctx context.Context, module *scanner.NodeModuleInfo, progress ProgressFunc) error {
+	return removeTree(ctx, module.Path, progress)
+}
@@ -0,0 +1,177 @@
+package deleter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// TargetName is the only directory name npmclean ever deletes
+const TargetName = "node_modules"
+
+// Guard runs the checks every tree has to pass right before it is deleted
+type Guard struct {
+	roots []string
+
+	mu sync.RWMutex
+	// protected are the directories nothing may be deleted from
+	protected []protectedPath
+}
+
+type protectedPath struct {
+	given    string
+	resolved string
+}
+
+// NewGuard returns a guard only allowing trees under one of roots
+func NewGuard(roots ...string) *Guard {
+	g := &Guard{}
+	for _, root := range roots {
+		g.roots = append(g.roots, cleanPath(root))
+	}
+	return g
+}
+
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// Protect stops anything under paths from being deleted
+func (g *Guard) Protect(paths ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, path := range paths {
+		if !slices.ContainsFunc(g.protected, func(p protectedPath) bool { return p.given == path }) {
+			g.protected = append(g.protected, protectedPath{given: path, resolved: cleanPath(path)})
+		}
+	}
+}
+
+// Unprotect removes paths as given to Protect
+func (g *Guard) Unprotect(paths ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.protected = slices.DeleteFunc(g.protected, func(p protectedPath) bool { return slices.Contains(paths, p.given) })
+}
+
+// ProtectedBy returns the protected directory path is in, as given to
+// Protect, empty if none. It doesn't touch the filesystem, so path should
+// be resolved already unless it is only for display.
+func (g *Guard) ProtectedBy(path string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, p := range g.protected {
+		if isUnder(path, p.given) || isUnder(path, p.resolved) {
+			return p.given
+		}
+	}
+	return ""
+}
+
+// Check reports why a tree must not be deleted, nil means it may be
+func (g *Guard) Check(module *scanner.NodeModuleInfo) error {
+	return g.CheckAll([]*scanner.NodeModuleInfo{module})[0]
+}
+
+// CheckAll checks several trees at once, looking up open files only once.
+// The result has an entry per module, nil for those that may be deleted.
+func (g *Guard) CheckAll(modules []*scanner.NodeModuleInfo) []error {
+	errs := make([]error, len(modules))
+	var resolved []string
+	for i, module := range modules {
+		path, err := g.checkPath(module.Path)
+		if err == nil {
+			err = checkGit(path)
+		}
+		errs[i] = err
+		resolved = append(resolved, path)
+	}
+
+	users, err := usersOf(resolved)
+	if err != nil {
+		// Not being able to tell is no reason to refuse, the other checks
+		// still apply
+		return errs
+	}
+	for i, user := range users {
+		if errs[i] == nil && user != "" {
+			errs[i] = fmt.Errorf("in use by %s", user)
+		}
+	}
+	return errs
+}
+
+// checkPath checks the tree itself and returns its resolved path
+func (g *Guard) checkPath(path string) (string, error) {
+	if filepath.Base(path) != TargetName {
+		return path, fmt.Errorf("not named %s", TargetName)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return path, errors.New("no longer exists")
+		}
+		return path, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, _ := os.Readlink(path)
+		return path, fmt.Errorf("is a symlink to %s", target)
+	}
+	if !info.IsDir() {
+		return path, errors.New("not a directory")
+	}
+
+	// A symlinked parent could lead anywhere, judge where the tree really is
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path, err
+	}
+	if len(g.roots) > 0 && !slices.ContainsFunc(g.roots, func(root string) bool { return isUnder(resolved, root) }) {
+		return resolved, fmt.Errorf("%s is outside the scan root", resolved)
+	}
+	for _, p := range []string{path, resolved} {
+		if by := g.ProtectedBy(p); by != "" {
+			return resolved, fmt.Errorf("protected by %s", by)
+		}
+	}
+	return resolved, nil
+}
+
+// checkGit refuses trees with files tracked by git, somebody committed them
+// on purpose
+func checkGit(path string) error {
+	cmd := exec.Command("git", "ls-files", "-z", "--", TargetName)
+	cmd.Dir = filepath.Dir(path)
+	out, err := cmd.Output()
+	if err != nil {
+		// Not a repository or no git at all
+		return nil
+	}
+	if n := bytes.Count(out, []byte{0}); n > 0 {
+		return fmt.Errorf("%d files are tracked in git", n)
+	}
+	return nil
+}
+
+// isUnder reports whether path is root or inside it
+func isUnder(path, root string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package deleter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// usersOf returns for every path a process working in it or holding a file in
+// it open, empty when there is none. Processes of other users are skipped as
+// their /proc entries can't be read.
+func usersOf(paths []string) ([]string, error) {
+	users := make([]string, len(paths))
+	if len(paths) == 0 {
+		return users, nil
+	}
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join("/proc", proc.Name())
+
+		var open []string
+		if cwd, err := os.Readlink(filepath.Join(dir, "cwd")); err == nil {
+			open = append(open, cwd)
+		}
+		if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err == nil {
+			for _, fd := range fds {
+				if target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name())); err == nil && filepath.IsAbs(target) {
+					open = append(open, target)
+				}
+			}
+		}
+
+		for _, o := range open {
+			for i, path := range paths {
+				if users[i] == "" && isUnder(o, path) {
+					users[i] = describeProcess(pid)
+				}
+			}
+		}
+	}
+	return users, nil
+}
+
+func describeProcess(pid int) string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return fmt.Sprintf("pid %d", pid)
+	}
+	return fmt.Sprintf("pid %d (%s)", pid, strings.TrimSpace(string(comm)))
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package deleter
+
+// usersOf needs /proc, elsewhere nothing is known to be in use
+func usersOf(paths []string) ([]string, error) {
+	return nil, ErrUnsupported
+}
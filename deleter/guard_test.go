@@ -0,0 +1,100 @@
+package deleter
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestGuard(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	mkdir := func(parts ...string) string {
+		path := filepath.Join(parts...)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	ok := mkdir(root, "app", "node_modules")
+	wrongName := mkdir(root, "app", "vendor")
+	far := mkdir(outside, "app", "node_modules")
+	link := filepath.Join(root, "linked", "node_modules")
+	mkdir(root, "linked")
+	if err := os.Symlink(far, link); err != nil {
+		t.Fatal(err)
+	}
+	// The tree itself is real but its parent leads out of the root
+	if err := os.Symlink(filepath.Join(outside, "app"), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	escaped := filepath.Join(root, "escape", "node_modules")
+	pinned := mkdir(root, "pinned", "node_modules")
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{ok, ""},
+		{wrongName, "not named node_modules"},
+		{filepath.Join(root, "gone", "node_modules"), "no longer exists"},
+		{link, "is a symlink"},
+		{escaped, "outside the scan root"},
+		{pinned, "protected by " + filepath.Join(root, "pinned")},
+	}
+
+	if _, err := exec.LookPath("git"); err == nil {
+		repo := mkdir(root, "repo")
+		vendored := mkdir(repo, "node_modules", "pkg")
+		if err := os.WriteFile(filepath.Join(vendored, "index.js"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git := func(args ...string) {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+		git("init", "-q")
+		git("add", "-f", "node_modules")
+		cases = append(cases, struct{ path, want string }{filepath.Join(repo, "node_modules"), "tracked in git"})
+	}
+
+	if runtime.GOOS == "linux" {
+		busy := mkdir(root, "busy", "node_modules")
+		cmd := exec.Command("sleep", "10")
+		cmd.Dir = busy
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}()
+		cases = append(cases, struct{ path, want string }{busy, "in use by"})
+	}
+
+	g := NewGuard(root)
+	g.Protect(filepath.Join(root, "pinned"), filepath.Join(root, "app"))
+	g.Unprotect(filepath.Join(root, "app"))
+	var modules []*scanner.NodeModuleInfo
+	for _, c := range cases {
+		modules = append(modules, &scanner.NodeModuleInfo{Path: c.path})
+	}
+	for i, err := range g.CheckAll(modules) {
+		c := cases[i]
+		switch {
+		case c.want == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", c.path, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)):
+			t.Errorf("%s: expected %q, got %v", c.path, c.want, err)
+		}
+	}
+}
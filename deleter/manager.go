@@ -0,0 +1,396 @@
+package deleter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// DefaultWorkers is how many trees are deleted at the same time by default
+const DefaultWorkers = 4
+
+// progressInterval throttles progress notifications for a single job
+const progressInterval = 100 * time.Millisecond
+
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobDone      JobState = "done"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Finished reports whether the job will not make any more progress
+func (s JobState) Finished() bool {
+	return s == JobDone || s == JobFailed || s == JobCancelled
+}
+
+// ProgressFunc receives the number of files and bytes processed so far
+type ProgressFunc func(files, bytes int64)
+
+// ProgressStrategy is implemented by strategies that can report progress and
+// stop part way through. Strategies that only rename are instant and don't
+// need to.
+type ProgressStrategy interface {
+	Strategy
+	DeleteContext(ctx context.Context, module *scanner.NodeModuleInfo, progress ProgressFunc) error
+}
+
+// Job is a snapshot of a queued deletion
+type Job struct {
+	ID         int
+	Module     *scanner.NodeModuleInfo
+	Strategy   Strategy
+	State      JobState
+	Files      int64
+	Bytes      int64
+	Err        error
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+type job struct {
+	Job
+	cancel     context.CancelFunc
+	lastNotify time.Time
+}
+
+// Manager deletes queued trees with a bounded number of workers. Jobs that
+// failed or were cancelled can be retried.
+type Manager struct {
+	strategy Strategy
+	guard    *Guard
+	workers  int
+	onUpdate func(Job)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []*job
+	queue   []*job
+	pending sync.WaitGroup
+	nextID  int
+	closed  bool
+	done    sync.WaitGroup
+}
+
+// NewManager starts workers goroutines deleting with strategy, zero or less
+// means DefaultWorkers. Every tree is run past guard right before it is
+// deleted, as things may have changed since it was queued. onUpdate, if not
+// nil, is called from the workers with a snapshot every time a job changes
+// state and periodically while it runs.
+func NewManager(strategy Strategy, guard *Guard, workers int, onUpdate func(Job)) *Manager {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if guard == nil {
+		guard = NewGuard()
+	}
+	m := &Manager{strategy: strategy, guard: guard, workers: workers, onUpdate: onUpdate}
+	m.cond = sync.NewCond(&m.mu)
+	m.done.Add(workers)
+	for range workers {
+		go m.worker()
+	}
+	return m
+}
+
+func (m *Manager) Strategy() Strategy {
+	return m.strategy
+}
+
+func (m *Manager) Guard() *Guard {
+	return m.guard
+}
+
+// SetOnUpdate replaces the function notified about job changes
+func (m *Manager) SetOnUpdate(fn func(Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onUpdate = fn
+}
+
+// Enqueue adds a deletion to the queue and returns its id, it must not be
+// called after Close
+func (m *Manager) Enqueue(module *scanner.NodeModuleInfo) int {
+	return m.EnqueueWith(m.strategy, module)
+}
+
+// EnqueueWith is Enqueue with a strategy other than the manager's, for
+// actions like relocating that share the queue with deletions
+func (m *Manager) EnqueueWith(strategy Strategy, module *scanner.NodeModuleInfo) int {
+	m.mu.Lock()
+	m.nextID++
+	j := &job{Job: Job{ID: m.nextID, Module: module, Strategy: strategy, State: JobQueued}}
+	m.jobs = append(m.jobs, j)
+	m.push(j)
+	snapshot := j.Job
+	m.mu.Unlock()
+
+	m.notify(snapshot)
+	return snapshot.ID
+}
+
+// push queues j, the caller holds mu
+func (m *Manager) push(j *job) {
+	m.pending.Add(1)
+	m.queue = append(m.queue, j)
+	m.cond.Signal()
+}
+
+// Cancel stops a queued or running job. A running deletion stops between
+// files, leaving whatever was not removed yet in place.
+func (m *Manager) Cancel(id int) bool {
+	m.mu.Lock()
+	j := m.find(id)
+	if j == nil || j.State.Finished() {
+		m.mu.Unlock()
+		return false
+	}
+	if j.State == JobRunning {
+		j.cancel()
+		m.mu.Unlock()
+		return true
+	}
+	m.finish(j, JobCancelled, context.Canceled)
+	snapshot := j.Job
+	m.mu.Unlock()
+
+	m.notify(snapshot)
+	return true
+}
+
+// CancelAll cancels every queued and running job
+func (m *Manager) CancelAll() {
+	for _, j := range m.Jobs() {
+		if !j.State.Finished() {
+			m.Cancel(j.ID)
+		}
+	}
+}
+
+// Retry queues a failed or cancelled job again
+func (m *Manager) Retry(id int) bool {
+	m.mu.Lock()
+	j := m.find(id)
+	if j == nil || m.closed || (j.State != JobFailed && j.State != JobCancelled) {
+		m.mu.Unlock()
+		return false
+	}
+	j.State = JobQueued
+	j.Err = nil
+	j.Files, j.Bytes = 0, 0
+	j.StartedAt, j.FinishedAt = time.Time{}, time.Time{}
+	m.push(j)
+	snapshot := j.Job
+	m.mu.Unlock()
+
+	m.notify(snapshot)
+	return true
+}
+
+// Jobs returns snapshots of every job in the order they were queued
+func (m *Manager) Jobs() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, len(m.jobs))
+	for i, j := range m.jobs {
+		jobs[i] = j.Job
+	}
+	return jobs
+}
+
+// Pending is the number of jobs queued or running
+func (m *Manager) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, j := range m.jobs {
+		if !j.State.Finished() {
+			n++
+		}
+	}
+	return n
+}
+
+// ClearFinished forgets jobs that will not run again unless retried
+func (m *Manager) ClearFinished() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = slices.DeleteFunc(m.jobs, func(j *job) bool { return j.State.Finished() })
+}
+
+// Wait blocks until nothing is queued or running
+func (m *Manager) Wait() {
+	m.pending.Wait()
+}
+
+// Close waits for queued work and stops the workers
+func (m *Manager) Close() {
+	m.Wait()
+	m.mu.Lock()
+	m.closed = true
+	m.cond.Broadcast()
+	m.mu.Unlock()
+	m.done.Wait()
+}
+
+func (m *Manager) worker() {
+	defer m.done.Done()
+	for {
+		m.mu.Lock()
+		for len(m.queue) == 0 && !m.closed {
+			m.cond.Wait()
+		}
+		if len(m.queue) == 0 {
+			m.mu.Unlock()
+			return
+		}
+		j := m.queue[0]
+		m.queue = m.queue[1:]
+		if j.State != JobQueued {
+			// Cancelled while it was waiting
+			m.mu.Unlock()
+			m.pending.Done()
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		j.cancel = cancel
+		j.State = JobRunning
+		j.StartedAt = time.Now()
+		snapshot := j.Job
+		m.mu.Unlock()
+
+		m.notify(snapshot)
+		err := m.run(ctx, j)
+		cancel()
+
+		m.mu.Lock()
+		switch {
+		case err == nil:
+			m.finish(j, JobDone, nil)
+		case errors.Is(err, context.Canceled):
+			m.finish(j, JobCancelled, err)
+		default:
+			m.finish(j, JobFailed, err)
+		}
+		snapshot = j.Job
+		m.mu.Unlock()
+
+		m.notify(snapshot)
+		m.pending.Done()
+	}
+}
+
+func (m *Manager) run(ctx context.Context, j *job) error {
+	if err := m.guard.Check(j.Module); err != nil {
+		return fmt.Errorf("blocked: %w", err)
+	}
+	ps, ok := j.Strategy.(ProgressStrategy)
+	if !ok {
+		if err := j.Strategy.Delete(j.Module); err != nil {
+			return err
+		}
+		m.progress(j, 0, j.Module.Size)
+		return nil
+	}
+	return ps.DeleteContext(ctx, j.Module, func(files, bytes int64) { m.progress(j, files, bytes) })
+}
+
+func (m *Manager) progress(j *job, files, bytes int64) {
+	m.mu.Lock()
+	j.Files, j.Bytes = files, bytes
+	now := time.Now()
+	if now.Sub(j.lastNotify) < progressInterval {
+		m.mu.Unlock()
+		return
+	}
+	j.lastNotify = now
+	snapshot := j.Job
+	m.mu.Unlock()
+
+	m.notify(snapshot)
+}
+
+// finish moves j to a final state, the caller holds mu
+func (m *Manager) finish(j *job, state JobState, err error) {
+	j.State = state
+	j.Err = err
+	j.FinishedAt = time.Now()
+}
+
+// find returns the job with id, the caller holds mu
+func (m *Manager) find(id int) *job {
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+func (m *Manager) notify(j Job) {
+	m.mu.Lock()
+	fn := m.onUpdate
+	m.mu.Unlock()
+	if fn != nil {
+		fn(j)
+	}
+}
+
+// removeTree deletes path file by file so progress can be reported and the
+// deletion stopped between files
+func removeTree(ctx context.Context, path string, progress ProgressFunc) error {
+	var files, bytes int64
+	var dirs []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+
+		var size int64
+		if info, err := d.Info(); err == nil {
+			size = info.Size()
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		files++
+		bytes += size
+		if progress != nil {
+			progress(files, bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Children were appended after their parents
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(dirs[i]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			// Leave anything unusual, like a read only directory, to RemoveAll
+			return os.RemoveAll(path)
+		}
+	}
+	return nil
+}
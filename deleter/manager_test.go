@@ -0,0 +1,107 @@
+package deleter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// flakyStrategy fails the first delete of every path and blocks deletes of
+// paths in hold until it is closed
+type flakyStrategy struct {
+	mu     sync.Mutex
+	failed map[string]bool
+	hold   map[string]chan struct{}
+}
+
+func (s *flakyStrategy) Name() string { return "flaky" }
+
+func (s *flakyStrategy) Delete(module *scanner.NodeModuleInfo) error {
+	if ch, ok := s.hold[module.Path]; ok {
+		<-ch
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.failed[module.Path] {
+		s.failed[module.Path] = true
+		return errors.New("busy")
+	}
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	root := t.TempDir()
+	var modules []*scanner.NodeModuleInfo
+	for _, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(root, name, "node_modules")
+		if err := os.MkdirAll(filepath.Join(path, "pkg", "lib"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []string{"index.js", "lib/util.js"} {
+			if err := os.WriteFile(filepath.Join(path, "pkg", f), make([]byte, 100), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		modules = append(modules, &scanner.NodeModuleInfo{Path: path, Size: 200})
+	}
+
+	m := NewManager(Remove{}, NewGuard(root), 2, nil)
+	for _, module := range modules {
+		m.Enqueue(module)
+	}
+	m.Close()
+	for _, j := range m.Jobs() {
+		if j.State != JobDone || j.Files != 2 || j.Bytes != 200 {
+			t.Fatalf("unexpected job: %+v", j)
+		}
+		if _, err := os.Stat(j.Module.Path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be gone, got %v", j.Module.Path, err)
+		}
+	}
+
+	// One worker busy with a held item, so the second one stays queued
+	heldPath, queuedPath := filepath.Join(root, "held", "node_modules"), filepath.Join(root, "queued", "node_modules")
+	for _, path := range []string{heldPath, queuedPath} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hold := make(chan struct{})
+	s := &flakyStrategy{failed: map[string]bool{}, hold: map[string]chan struct{}{heldPath: hold}}
+	m = NewManager(s, NewGuard(root), 1, nil)
+	held := m.Enqueue(&scanner.NodeModuleInfo{Path: heldPath})
+	queued := m.Enqueue(&scanner.NodeModuleInfo{Path: queuedPath})
+	if !m.Cancel(queued) {
+		t.Fatal("failed to cancel a queued job")
+	}
+	close(hold)
+	m.Wait()
+
+	states := map[int]JobState{}
+	for _, j := range m.Jobs() {
+		states[j.ID] = j.State
+	}
+	if states[held] != JobFailed || states[queued] != JobCancelled {
+		t.Fatalf("unexpected states: %v", states)
+	}
+	if m.Pending() != 0 {
+		t.Fatalf("expected nothing pending, got %d", m.Pending())
+	}
+
+	if !m.Retry(held) || !m.Retry(queued) {
+		t.Fatal("failed to retry")
+	}
+	m.Close()
+	for _, j := range m.Jobs() {
+		if j.ID == queued && j.State != JobFailed {
+			t.Fatalf("retried cancelled job should run and fail once: %+v", j)
+		}
+		if j.ID == held && j.State != JobDone {
+			t.Fatalf("retried failed job should succeed: %+v", j)
+		}
+	}
+}
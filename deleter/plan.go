@@ -0,0 +1,137 @@
+package deleter
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// PlanItem is a tree a cleanup would delete, Blocked is why it would not
+type PlanItem struct {
+	Module  *scanner.NodeModuleInfo
+	Blocked string
+	// Frees is the Estimate of what the strategy frees on this tree
+	Frees int64
+}
+
+// Plan is what a cleanup would do, it is built before touching anything so
+// it can be confirmed, or reported on its own in dry run mode
+type Plan struct {
+	Strategy string
+	Items    []PlanItem
+	// Goal is how many bytes a target plan is meant to free, zero otherwise
+	Goal int64
+}
+
+// NewPlan runs every module past guard and records why the blocked ones
+// can't be deleted
+func NewPlan(strategy Strategy, guard *Guard, modules []*scanner.NodeModuleInfo) *Plan {
+	p := &Plan{Strategy: strategy.Name()}
+	errs := guard.CheckAll(modules)
+	for i, module := range modules {
+		item := PlanItem{Module: module, Frees: Estimate(strategy, module)}
+		if errs[i] != nil {
+			item.Blocked = errs[i].Error()
+		}
+		p.Items = append(p.Items, item)
+	}
+	return p
+}
+
+// Allowed returns the modules that are not blocked
+func (p *Plan) Allowed() []*scanner.NodeModuleInfo {
+	var modules []*scanner.NodeModuleInfo
+	for _, item := range p.Items {
+		if item.Blocked == "" {
+			modules = append(modules, item.Module)
+		}
+	}
+	return modules
+}
+
+// Short is how many bytes the plan falls short of its goal
+func (p *Plan) Short() int64 {
+	return max(p.Goal-p.Frees(), 0)
+}
+
+// Blocked returns the items that would be skipped
+func (p *Plan) Blocked() []PlanItem {
+	var items []PlanItem
+	for _, item := range p.Items {
+		if item.Blocked != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Bytes is how much the allowed modules take up
+func (p *Plan) Bytes() int64 {
+	var bytes int64
+	for _, module := range p.Allowed() {
+		bytes += module.Size
+	}
+	return bytes
+}
+
+// Frees is how much running the strategy on the allowed modules is expected
+// to free, less than Bytes when the trees stay on the filesystem
+func (p *Plan) Frees() int64 {
+	var bytes int64
+	for _, item := range p.Items {
+		if item.Blocked == "" {
+			bytes += item.Frees
+		}
+	}
+	return bytes
+}
+
+// WriteReport writes a human readable summary of the plan listing at most
+// limit items of each kind, zero means all of them
+func (p *Plan) WriteReport(w io.Writer, limit int) error {
+	allowed := p.Allowed()
+	if _, err := fmt.Fprintf(w, "Would %s %d items of %s, freeing %s\n", p.Strategy, len(allowed), humanize.Bytes(uint64(p.Bytes())), humanize.Bytes(uint64(p.Frees()))); err != nil {
+		return err
+	}
+	if p.Goal > 0 {
+		if _, err := fmt.Fprintf(w, "Goal %s, %s\n", humanize.Bytes(uint64(p.Goal)), p.goalStatus()); err != nil {
+			return err
+		}
+	}
+	for i, module := range allowed {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(w, "  ... and %d more\n", len(allowed)-limit)
+			break
+		}
+		if _, err := fmt.Fprintf(w, "  %8s  %s\n", humanize.Bytes(uint64(module.Size)), module.Path); err != nil {
+			return err
+		}
+	}
+
+	blocked := p.Blocked()
+	if len(blocked) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "\nBlocked %d items\n", len(blocked)); err != nil {
+		return err
+	}
+	for i, item := range blocked {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(w, "  ... and %d more\n", len(blocked)-limit)
+			break
+		}
+		if _, err := fmt.Fprintf(w, "  %s: %s\n", item.Module.Path, item.Blocked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plan) goalStatus() string {
+	if short := p.Short(); short > 0 {
+		return fmt.Sprintf("short by %s", humanize.Bytes(uint64(short)))
+	}
+	return "met"
+}
@@ -0,0 +1,127 @@
+package deleter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestPlan(t *testing.T) {
+	root := t.TempDir()
+	present := filepath.Join(root, "app", "node_modules")
+	if err := os.MkdirAll(present, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(root, "gone", "node_modules")
+
+	plan := NewPlan(Remove{}, NewGuard(root), []*scanner.NodeModuleInfo{
+		{Path: present, Size: 1000},
+		{Path: gone, Size: 500},
+	})
+	if allowed := plan.Allowed(); len(allowed) != 1 || allowed[0].Path != present {
+		t.Fatalf("unexpected allowed items: %v", allowed)
+	}
+	if blocked := plan.Blocked(); len(blocked) != 1 || blocked[0].Module.Path != gone {
+		t.Fatalf("unexpected blocked items: %v", blocked)
+	}
+	if plan.Bytes() != 1000 {
+		t.Fatalf("expected 1000 bytes, got %d", plan.Bytes())
+	}
+
+	var report strings.Builder
+	if err := plan.WriteReport(&report, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "Would remove 1 items") || !strings.Contains(report.String(), gone+": no longer exists") {
+		t.Fatalf("unexpected report:\n%s", report.String())
+	}
+	if _, err := os.Stat(present); err != nil {
+		t.Fatalf("planning touched the tree: %v", err)
+	}
+}
+
+func TestTargetPlan(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	var modules []*scanner.NodeModuleInfo
+	for i, name := range []string{"fresh", "stale", "staler", "pinned"} {
+		path := filepath.Join(root, name, "node_modules")
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		modules = append(modules, &scanner.NodeModuleInfo{
+			Path:           path,
+			Size:           int64(100 * (i + 1)),
+			LastModifiedAt: now.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+	guard := NewGuard(root)
+	guard.Protect(filepath.Join(root, "pinned"))
+
+	paths := func(modules []*scanner.NodeModuleInfo) []string {
+		var names []string
+		for _, m := range modules {
+			names = append(names, filepath.Base(filepath.Dir(m.Path)))
+		}
+		return names
+	}
+
+	// The pinned tree is the stalest, it is skipped on the way
+	plan, err := NewTargetPlan(Remove{}, guard, modules, 500, OrderStalest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(plan.Allowed()); !slices.Equal(got, []string{"staler", "stale"}) || len(plan.Blocked()) != 1 || plan.Short() != 0 {
+		t.Fatalf("unexpected stalest plan: %v, %d blocked, short %d", got, len(plan.Blocked()), plan.Short())
+	}
+
+	plan, err = NewTargetPlan(Remove{}, guard, modules, 350, OrderLargest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(plan.Allowed()); !slices.Equal(got, []string{"staler", "stale"}) {
+		t.Fatalf("unexpected largest plan: %v", got)
+	}
+
+	plan, _ = NewTargetPlan(Remove{}, guard, modules, 10_000, OrderLargest)
+	if len(plan.Allowed()) != 3 || plan.Short() != 10_000-600 {
+		t.Fatalf("expected every allowed tree and a shortfall, got %v short %d", paths(plan.Allowed()), plan.Short())
+	}
+}
+
+func TestTargetPlanEstimates(t *testing.T) {
+	root := t.TempDir()
+	var modules []*scanner.NodeModuleInfo
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(root, name, "node_modules")
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		modules = append(modules, &scanner.NodeModuleInfo{Path: path, Size: 100})
+	}
+
+	// Quarantined trees stay on the filesystem, none of them gets closer to the goal
+	q := NewQuarantine(nil, filepath.Join(root, "staging"))
+	plan, err := NewTargetPlan(q, NewGuard(root), modules, 150, OrderLargest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Allowed()) != 0 || plan.Frees() != 0 || plan.Short() != 150 {
+		t.Fatalf("expected an empty plan short by the goal, got %d items, frees %d, short %d", len(plan.Allowed()), plan.Frees(), plan.Short())
+	}
+
+	plan = NewPlan(q, NewGuard(root), modules)
+	if plan.Bytes() != 200 || plan.Frees() != 0 {
+		t.Fatalf("expected 200 bytes freeing nothing, got %d and %d", plan.Bytes(), plan.Frees())
+	}
+	var report strings.Builder
+	plan.WriteReport(&report, 0)
+	if !strings.Contains(report.String(), "Would quarantine 2 items of 200 B, freeing 0 B") {
+		t.Fatalf("unexpected report:\n%s", report.String())
+	}
+}
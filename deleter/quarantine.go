@@ -0,0 +1,160 @@
+package deleter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// DefaultQuarantineTTL is how long quarantined trees are kept by default
+const DefaultQuarantineTTL = 7 * 24 * time.Hour
+
+// Quarantine renames trees into a staging area on the same filesystem, which
+// is instant, and records them in the cache so they can be restored until
+// they are purged
+type Quarantine struct {
+	store cache.Store
+	// dir is the preferred staging area, used when it is on the same
+	// filesystem as the tree being deleted
+	dir string
+}
+
+func NewQuarantine(store cache.Store, dir string) *Quarantine {
+	return &Quarantine{store: store, dir: dir}
+}
+
+func (q *Quarantine) Name() string {
+	return StrategyQuarantine
+}
+
+func (q *Quarantine) Delete(module *scanner.NodeModuleInfo) error {
+	staging, err := q.stagingDir(module.Path)
+	if err != nil {
+		return err
+	}
+
+	proj := project.Detect(module.Path)
+	name := fmt.Sprintf("%d-%s", time.Now().UnixNano(), strings.ReplaceAll(proj.Name, "/", "_"))
+	staged := filepath.Join(staging, name)
+	if err := os.Rename(module.Path, staged); err != nil {
+		return fmt.Errorf("failed to quarantine: %w", err)
+	}
+
+	item := &cache.QuarantineItem{
+		OriginalPath:   module.Path,
+		StagedPath:     staged,
+		Size:           module.Size,
+		PackageManager: string(proj.PackageManager),
+		ProjectName:    proj.Name,
+		QuarantinedAt:  time.Now(),
+	}
+	if err := q.store.AddQuarantine(item); err != nil {
+		// Without a record nobody could restore or purge it, put it back
+		if rerr := os.Rename(staged, module.Path); rerr != nil {
+			return fmt.Errorf("failed to record quarantine: %w (tree left in %s: %v)", err, staged, rerr)
+		}
+		return fmt.Errorf("failed to record quarantine: %w", err)
+	}
+	return nil
+}
+
+func (q *Quarantine) stagingDir(path string) (string, error) {
+	if q.dir != "" {
+		if err := os.MkdirAll(q.dir, 0o700); err == nil && sameVolume(q.dir, path) {
+			return q.dir, nil
+		}
+	}
+
+	// Fall back to the top of the volume, like the per volume trash does
+	top, err := mountPoint(path)
+	if err != nil {
+		return "", err
+	}
+	name := ".npmclean-quarantine"
+	if uid := os.Getuid(); uid >= 0 {
+		name = fmt.Sprintf("%s-%d", name, uid)
+	}
+	dir := filepath.Join(top, name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("no usable quarantine on %s: %w", top, err)
+	}
+	return dir, nil
+}
+
+// Restore moves a quarantined tree back where it came from
+func Restore(store cache.Store, item *cache.QuarantineItem) error {
+	if _, err := os.Lstat(item.OriginalPath); err == nil {
+		return fmt.Errorf("%s already exists", item.OriginalPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.OriginalPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(item.StagedPath, item.OriginalPath); err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+	return store.RemoveQuarantine(item.ID)
+}
+
+// Purge deletes a quarantined tree for good and logs the reclaimed space
+func Purge(store cache.Store, item *cache.QuarantineItem) error {
+	if err := os.RemoveAll(item.StagedPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := store.RemoveQuarantine(item.ID); err != nil {
+		return err
+	}
+	return store.RecordDeletion(&cache.DeletionRecord{
+		Path:           item.OriginalPath,
+		Size:           item.Size,
+		PackageManager: item.PackageManager,
+		ProjectName:    item.ProjectName,
+		DeletedAt:      time.Now(),
+		Outcome:        cache.OutcomeDeleted,
+	})
+}
+
+// Expired returns the items quarantined longer than ttl ago
+func Expired(store cache.Store, ttl time.Duration) ([]*cache.QuarantineItem, error) {
+	items, err := store.Quarantined()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-ttl)
+	var expired []*cache.QuarantineItem
+	for _, item := range items {
+		if !item.QuarantinedAt.After(cutoff) {
+			expired = append(expired, item)
+		}
+	}
+	return expired, nil
+}
+
+// PurgeExpired purges every item quarantined longer than ttl ago and returns
+// the purged items. It keeps going on errors and returns the first one.
+func PurgeExpired(store cache.Store, ttl time.Duration) ([]*cache.QuarantineItem, error) {
+	items, err := Expired(store, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []*cache.QuarantineItem
+	var firstErr error
+	for _, item := range items {
+		if err := Purge(store, item); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to purge %s: %w", item.StagedPath, err)
+			}
+			continue
+		}
+		purged = append(purged, item)
+	}
+	return purged, firstErr
+}
@@ -0,0 +1,54 @@
+package deleter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestQuarantineRestorePurge(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app", "node_modules")
+	if err := os.MkdirAll(filepath.Join(path, "left-pad"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	store := cache.NewMemoryStore()
+	q := NewQuarantine(store, filepath.Join(root, "staging"))
+	module := &scanner.NodeModuleInfo{Path: path, Size: 4096}
+
+	if err := q.Delete(module); err != nil {
+		t.Fatalf("quarantine: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be gone, got %v", path, err)
+	}
+
+	items, _ := store.Quarantined()
+	if len(items) != 1 || items[0].OriginalPath != path || items[0].Size != module.Size {
+		t.Fatalf("unexpected quarantine: %+v", items)
+	}
+	if err := Restore(store, items[0]); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "left-pad")); err != nil {
+		t.Fatalf("restored tree is incomplete: %v", err)
+	}
+
+	if err := q.Delete(module); err != nil {
+		t.Fatalf("quarantine again: %v", err)
+	}
+	purged, err := PurgeExpired(store, 0)
+	if err != nil || len(purged) != 1 {
+		t.Fatalf("purge: %d %v", len(purged), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "staging")); len(entries) != 0 {
+		t.Fatalf("staging area not empty: %v", entries)
+	}
+	if records, _ := store.Deletions(purged[0].QuarantinedAt); len(records) != 1 || records[0].Outcome != cache.OutcomeDeleted {
+		t.Fatalf("purge was not logged: %+v", records)
+	}
+}
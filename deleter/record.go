@@ -0,0 +1,43 @@
+package deleter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// Record logs what running strategy on module did in the deletion history,
+// err is the error it failed with
+func Record(store cache.Store, module *scanner.NodeModuleInfo, strategy Strategy, err error) error {
+	proj := project.Detect(module.Path)
+	rec := &cache.DeletionRecord{
+		Path:           module.Path,
+		Size:           module.Size,
+		PackageManager: string(proj.PackageManager),
+		ProjectName:    proj.Name,
+		DeletedAt:      time.Now(),
+		Outcome:        cache.OutcomeDeleted,
+	}
+	switch strategy.Name() {
+	case StrategyTrash:
+		rec.Outcome = cache.OutcomeTrashed
+	case StrategyQuarantine:
+		rec.Outcome = cache.OutcomeQuarantined
+	case StrategyArchive:
+		rec.Outcome = cache.OutcomeArchived
+	case StrategyRelocate:
+		rec.Outcome = cache.OutcomeRelocated
+	}
+	if err != nil {
+		rec.Outcome = cache.OutcomeFailed
+		if errors.Is(err, context.Canceled) {
+			rec.Outcome = cache.OutcomeCancelled
+		}
+		rec.Error = err.Error()
+	}
+	return store.RecordDeletion(rec)
+}
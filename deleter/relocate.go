@@ -0,0 +1,267 @@
+package deleter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// StrategyRelocate is not a deletion strategy, relocating is its own action
+// that shares the deletion queue
+const StrategyRelocate = "relocate"
+
+// Relocator moves trees to cold storage, typically a bigger and slower disk,
+// and leaves a symlink behind so projects keep working. The tree is mirrored
+// under dir by its full path.
+type Relocator struct {
+	dir string
+}
+
+func NewRelocator(dir string) *Relocator {
+	return &Relocator{dir: dir}
+}
+
+func (r *Relocator) Name() string {
+	return StrategyRelocate
+}
+
+func (r *Relocator) Delete(module *scanner.NodeModuleInfo) error {
+	return r.DeleteContext(context.Background(), module, nil)
+}
+
+// Target is where the tree at path is moved to
+func (r *Relocator) Target(path string) string {
+	return filepath.Join(r.dir, strings.TrimPrefix(path, filepath.VolumeName(path)))
+}
+
+// Frees is how much space relocating the tree makes on its filesystem,
+// nothing when cold storage is on the same one and the tree is only renamed
+func (r *Relocator) Frees(module *scanner.NodeModuleInfo) int64 {
+	if sameVolume(filepath.Dir(module.Path), r.dir) {
+		return 0
+	}
+	return module.Size
+}
+
+// DeleteContext renames the tree into cold storage, or copies and verifies it
+// when cold storage is on another filesystem. Nothing changes at the original
+// path until the copy is complete, a failure or cancellation before that
+// removes the partial copy.
+func (r *Relocator) DeleteContext(ctx context.Context, module *scanner.NodeModuleInfo, progress ProgressFunc) error {
+	src := module.Path
+	dst := r.Target(src)
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("no usable cold storage: %w", err)
+	}
+
+	err := os.Rename(src, dst)
+	if err == nil {
+		if err := os.Symlink(dst, src); err != nil {
+			if rerr := os.Rename(dst, src); rerr != nil {
+				return fmt.Errorf("failed to link %s: %w (tree left in %s: %v)", src, err, dst, rerr)
+			}
+			return fmt.Errorf("failed to link %s: %w", src, err)
+		}
+		if progress != nil {
+			progress(0, module.Size)
+		}
+		return nil
+	}
+	if !crossDevice(err) {
+		return fmt.Errorf("failed to move: %w", err)
+	}
+
+	partial := dst + ".partial"
+	os.RemoveAll(partial) // left over from a crash
+	if err := copyTree(ctx, src, partial, progress); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("failed to copy: %w", err)
+	}
+	if err := verifyTree(src, partial); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("copy does not match: %w", err)
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		os.RemoveAll(partial)
+		return err
+	}
+
+	// Swap the tree for the link, the old tree is only removed once the link
+	// is in place
+	old := filepath.Join(filepath.Dir(src), fmt.Sprintf(".%s.npmclean-%d", filepath.Base(src), time.Now().UnixNano()))
+	if err := os.Rename(src, old); err != nil {
+		os.RemoveAll(dst)
+		return fmt.Errorf("failed to move: %w", err)
+	}
+	if err := os.Symlink(dst, src); err != nil {
+		if rerr := os.Rename(old, src); rerr != nil {
+			return fmt.Errorf("failed to link %s: %w (tree left in %s: %v)", src, err, old, rerr)
+		}
+		os.RemoveAll(dst)
+		return fmt.Errorf("failed to link %s: %w", src, err)
+	}
+	// Past the point of no return, a cancel would only leave a half removed
+	// copy behind
+	if err := removeTree(context.Background(), old, nil); err != nil {
+		return fmt.Errorf("moved to %s, but failed to remove the old tree %s: %w", dst, old, err)
+	}
+	return nil
+}
+
+// copyTree copies the tree at src to dst keeping permissions, modification
+// times and symlinks. Hardlinks become separate files.
+func copyTree(ctx context.Context, src, dst string, progress ProgressFunc) error {
+	var files, bytes int64
+	type dirMeta struct {
+		path string
+		info fs.FileInfo
+	}
+	var dirs []dirMeta
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			if err := os.Mkdir(target, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirMeta{target, info})
+			return nil
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target, info); err != nil {
+				return err
+			}
+		default:
+			// Sockets and fifos don't survive a move anyway
+			return nil
+		}
+
+		files++
+		bytes += info.Size()
+		if progress != nil {
+			progress(files, bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Children were appended after their parents, and writing into a
+	// directory changes its modification time
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, d.info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.info.ModTime(), d.info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// verifyTree checks that every file, directory and symlink in src has a
+// counterpart of the same kind and size in dst
+func verifyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		want, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !want.IsDir() && !want.Mode().IsRegular() && want.Mode()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		got, err := os.Lstat(filepath.Join(dst, rel))
+		if err != nil {
+			return err
+		}
+		if got.Mode().Type() != want.Mode().Type() {
+			return fmt.Errorf("%s is a %v, want %v", rel, got.Mode().Type(), want.Mode().Type())
+		}
+		if want.Mode().IsRegular() && got.Size() != want.Size() {
+			return fmt.Errorf("%s has %d bytes, want %d", rel, got.Size(), want.Size())
+		}
+		if want.Mode()&fs.ModeSymlink != 0 {
+			wl, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			gl, err := os.Readlink(filepath.Join(dst, rel))
+			if err != nil {
+				return err
+			}
+			if gl != wl {
+				return fmt.Errorf("%s links to %q, want %q", rel, gl, wl)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package deleter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestRelocate(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app", "node_modules")
+	if err := os.MkdirAll(filepath.Join(path, "left-pad", ".bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "left-pad", "index.js"), make([]byte, 100), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../index.js", filepath.Join(path, "left-pad", ".bin", "left-pad")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRelocator(filepath.Join(root, "cold"))
+	if err := r.Delete(&scanner.NodeModuleInfo{Path: path, Size: 100}); err != nil {
+		t.Fatalf("relocate: %v", err)
+	}
+	if target, err := os.Readlink(path); err != nil || target != r.Target(path) {
+		t.Fatalf("expected a link to %s, got %q %v", r.Target(path), target, err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "left-pad", "index.js")); err != nil {
+		t.Fatalf("tree not reachable through the link: %v", err)
+	}
+	if err := r.Delete(&scanner.NodeModuleInfo{Path: path}); err == nil {
+		t.Fatal("relocating onto an existing target should fail")
+	}
+
+	// The copy used across filesystems
+	src := r.Target(path)
+	dst := filepath.Join(root, "copy")
+	var files, bytes int64
+	if err := copyTree(context.Background(), src, dst, func(f, b int64) { files, bytes = f, b }); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if files != 1 || bytes != 100 {
+		t.Fatalf("unexpected progress: %d files, %d bytes", files, bytes)
+	}
+	if err := verifyTree(src, dst); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "left-pad", "index.js")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("mode not kept: %v %v", info, err)
+	}
+	if err := os.Truncate(filepath.Join(dst, "left-pad", "index.js"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyTree(src, dst); err == nil {
+		t.Fatal("verify should catch a short file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := copyTree(ctx, src, filepath.Join(root, "cancelled"), nil); err == nil {
+		t.Fatal("copy should stop when cancelled")
+	}
+}
+
+func TestRelocateEstimate(t *testing.T) {
+	root := t.TempDir()
+	module := &scanner.NodeModuleInfo{Path: filepath.Join(root, "app", "node_modules"), Size: 100}
+	if err := os.MkdirAll(module.Path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := Estimate(Remove{}, module); got != 100 {
+		t.Errorf("remove: expected 100, got %d", got)
+	}
+
+	// A rename within the filesystem frees nothing
+	cold := filepath.Join(root, "cold")
+	if err := os.Mkdir(cold, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := Estimate(NewRelocator(cold), module); got != 0 {
+		t.Errorf("relocate on the same filesystem: expected 0, got %d", got)
+	}
+	// Cold storage that doesn't exist yet can't be compared, assume the copy
+	if got := Estimate(NewRelocator(filepath.Join(root, "missing")), module); got != 100 {
+		t.Errorf("relocate to missing cold storage: expected 100, got %d", got)
+	}
+}
@@ -0,0 +1,12 @@
+package deleter
+
+import "github.com/dustin/go-humanize"
+
+// SignedBytes formats a change in free space, which goes negative when other
+// programs wrote more than the deletions freed
+func SignedBytes(n int64) string {
+	if n < 0 {
+		return "-" + humanize.Bytes(uint64(-n))
+	}
+	return humanize.Bytes(uint64(n))
+}
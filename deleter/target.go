@@ -0,0 +1,112 @@
+package deleter
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// Orders in which NewTargetPlan considers trees
+const (
+	OrderStalest = "stalest"
+	OrderLargest = "largest"
+)
+
+var Orders = []string{OrderStalest, OrderLargest}
+
+// targetChunk is how many candidates are run past the guard at a time, the
+// checks are not free and usually only the first few trees are needed
+const targetChunk = 16
+
+// NewTargetPlan picks trees from modules until the Estimate of what strategy
+// frees on them reaches goal bytes, the stalest or the largest first. Blocked
+// trees are skipped and reported, trees freeing nothing are left out. The plan
+// falls short of the goal when the allowed trees run out.
+func NewTargetPlan(strategy Strategy, guard *Guard, modules []*scanner.NodeModuleInfo, goal int64, order string) (*Plan, error) {
+	candidates := slices.Clone(modules)
+	switch order {
+	case OrderStalest, "":
+		slices.SortStableFunc(candidates, func(a, b *scanner.NodeModuleInfo) int {
+			if c := a.LastModifiedAt.Compare(b.LastModifiedAt); c != 0 {
+				return c
+			}
+			return cmp.Compare(b.Size, a.Size)
+		})
+	case OrderLargest:
+		slices.SortStableFunc(candidates, func(a, b *scanner.NodeModuleInfo) int {
+			return cmp.Compare(b.Size, a.Size)
+		})
+	default:
+		return nil, fmt.Errorf("unknown order %q (want one of %v)", order, Orders)
+	}
+
+	p := &Plan{Strategy: strategy.Name(), Goal: goal}
+	var bytes int64
+	for len(candidates) > 0 && bytes < goal {
+		chunk := candidates[:min(targetChunk, len(candidates))]
+		candidates = candidates[len(chunk):]
+		errs := guard.CheckAll(chunk)
+		for i, module := range chunk {
+			if bytes >= goal {
+				break
+			}
+			item := PlanItem{Module: module, Frees: Estimate(strategy, module)}
+			if errs[i] != nil {
+				item.Blocked = errs[i].Error()
+			} else if item.Frees == 0 {
+				// Doesn't get any closer to the goal
+				continue
+			} else {
+				bytes += item.Frees
+			}
+			p.Items = append(p.Items, item)
+		}
+	}
+	return p, nil
+}
+
+// Goal is how much space to make, either a free space target for the
+// filesystem or an amount to reclaim
+type Goal struct {
+	Bytes   int64
+	Reclaim bool
+}
+
+// ParseGoal reads "20GB" as a free space target and "+5GB" as an amount to
+// reclaim
+func ParseGoal(s string) (Goal, error) {
+	s = strings.TrimSpace(s)
+	rest, reclaim := strings.CutPrefix(s, "+")
+	n, err := humanize.ParseBytes(rest)
+	if err != nil {
+		return Goal{}, fmt.Errorf("invalid goal %q, want e.g. 20GB free or +5GB to reclaim", s)
+	}
+	if n == 0 {
+		return Goal{}, fmt.Errorf("invalid goal %q, it must be more than nothing", s)
+	}
+	return Goal{Bytes: int64(n), Reclaim: reclaim}, nil
+}
+
+// Need is how many bytes have to be freed on the filesystem of path to meet
+// the goal, zero or less when it is already met
+func (g Goal) Need(path string) (int64, error) {
+	if g.Reclaim {
+		return g.Bytes, nil
+	}
+	free, err := FreeSpace(path)
+	if err != nil {
+		return 0, err
+	}
+	return g.Bytes - free, nil
+}
+
+func (g Goal) String() string {
+	if g.Reclaim {
+		return "reclaim " + humanize.Bytes(uint64(g.Bytes))
+	}
+	return humanize.Bytes(uint64(g.Bytes)) + " free"
+}
@@ -0,0 +1,160 @@
+//go:build linux
+
+package deleter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// Trash moves trees into the freedesktop.org trash so they can be restored
+// with the usual desktop tools, see
+// https://specifications.freedesktop.org/trash-spec/latest/
+type Trash struct {
+	homeTrash string
+	homeDev   uint64
+	uid       int
+}
+
+func NewTrash() (*Trash, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(dataHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	t := &Trash{homeTrash: filepath.Join(dataHome, "Trash"), uid: os.Getuid()}
+	if err := os.MkdirAll(t.homeTrash, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create trash: %w", err)
+	}
+	dev, err := deviceOf(t.homeTrash)
+	if err != nil {
+		return nil, err
+	}
+	t.homeDev = dev
+	return t, nil
+}
+
+func (t *Trash) Name() string {
+	return StrategyTrash
+}
+
+func (t *Trash) Delete(module *scanner.NodeModuleInfo) error {
+	path, err := filepath.Abs(module.Path)
+	if err != nil {
+		return err
+	}
+	dev, err := deviceOf(path)
+	if err != nil {
+		return err
+	}
+
+	// The home trash takes files of its own filesystem, everything else goes
+	// to the trash at the top of the volume so deleting is a cheap rename
+	trashDir, infoPath := t.homeTrash, path
+	if dev != t.homeDev {
+		topdir, err := mountPoint(path)
+		if err != nil {
+			return err
+		}
+		if trashDir, err = t.volumeTrash(topdir); err != nil {
+			return err
+		}
+		// Paths in a volume trash are relative to its top directory
+		if rel, err := filepath.Rel(topdir, path); err == nil {
+			infoPath = rel
+		}
+	}
+
+	return trashInto(trashDir, path, infoPath)
+}
+
+// volumeTrash picks $topdir/.Trash/$uid if the admin provided a shared trash,
+// falling back to $topdir/.Trash-$uid
+func (t *Trash) volumeTrash(topdir string) (string, error) {
+	uid := strconv.Itoa(t.uid)
+
+	shared := filepath.Join(topdir, ".Trash")
+	// The shared trash must be a real directory with the sticky bit set,
+	// otherwise the spec says to ignore it
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := os.MkdirAll(dir, 0o700); err == nil {
+			return dir, nil
+		}
+	}
+
+	dir := filepath.Join(topdir, ".Trash-"+uid)
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("no usable trash on %s: %w", topdir, err)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("no usable trash on %s", topdir)
+	}
+	return dir, nil
+}
+
+func trashInto(trashDir, path, infoPath string) error {
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		escapeTrashPath(infoPath), time.Now().Format("2006-01-02T15:04:05"))
+
+	// Claim a unique name by creating the .trashinfo file exclusively, as the
+	// spec requires, then move the tree next to it
+	base := filepath.Base(path)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+
+		infoFile := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(info)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(infoFile)
+			return err
+		}
+
+		if err := os.Rename(path, filepath.Join(filesDir, name)); err != nil {
+			os.Remove(infoFile)
+			return fmt.Errorf("failed to move to trash: %w", err)
+		}
+		return nil
+	}
+}
+
+func escapeTrashPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,162 @@
+package deleter
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestTrashHome(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	trash, err := NewTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	homeTrash := filepath.Join(root, "data", "Trash")
+
+	// Two trees with the same name, the second gets a numbered entry
+	var paths []string
+	for _, project := range []string{"my app%1", "other"} {
+		path := filepath.Join(root, project, "node_modules")
+		if err := os.MkdirAll(filepath.Join(path, "left-pad"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := trash.Delete(&scanner.NodeModuleInfo{Path: path}); err != nil {
+			t.Fatalf("trash %s: %v", path, err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be gone, got %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+
+	for i, name := range []string{"node_modules", "node_modules.2"} {
+		if _, err := os.Stat(filepath.Join(homeTrash, "files", name, "left-pad")); err != nil {
+			t.Errorf("tree not in the trash: %v", err)
+		}
+		info, err := os.ReadFile(filepath.Join(homeTrash, "info", name+".trashinfo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "Path=" + escapeTrashPath(paths[i]) + "\n"
+		if !strings.HasPrefix(string(info), "[Trash Info]\n") || !strings.Contains(string(info), want) {
+			t.Errorf("unexpected %s.trashinfo, want %q in:\n%s", name, want, info)
+		}
+	}
+	if info, _ := os.ReadFile(filepath.Join(homeTrash, "info", "node_modules.trashinfo")); !strings.Contains(string(info), "/my%20app%251/") {
+		t.Errorf("Path= is not percent-encoded:\n%s", info)
+	}
+}
+
+func TestTrashInfoCollision(t *testing.T) {
+	root := t.TempDir()
+	trashDir := filepath.Join(root, ".Trash-1000")
+	if err := os.MkdirAll(filepath.Join(trashDir, "info"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	// A .trashinfo without its tree still claims the name
+	if err := os.WriteFile(filepath.Join(trashDir, "info", "node_modules.trashinfo"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(root, "app", "node_modules")
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := trashInto(trashDir, path, "app/node_modules"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, "files", "node_modules.2")); err != nil {
+		t.Fatalf("expected the tree under the next free name: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, "files", "node_modules")); !os.IsNotExist(err) {
+		t.Fatalf("claimed name was reused: %v", err)
+	}
+	// Volume trashes record the path relative to the top directory
+	info, err := os.ReadFile(filepath.Join(trashDir, "info", "node_modules.2.trashinfo"))
+	if err != nil || !strings.Contains(string(info), "\nPath=app/node_modules\n") {
+		t.Fatalf("unexpected trashinfo %q: %v", info, err)
+	}
+}
+
+func TestVolumeTrash(t *testing.T) {
+	trash := &Trash{uid: 1000}
+	uid := strconv.Itoa(trash.uid)
+
+	tests := []struct {
+		name  string
+		setup func(topdir string) error
+		want  string
+	}{
+		{"no shared trash", func(string) error { return nil }, ".Trash-" + uid},
+		{"shared trash", func(topdir string) error {
+			if err := os.Mkdir(filepath.Join(topdir, ".Trash"), 0o777); err != nil {
+				return err
+			}
+			return os.Chmod(filepath.Join(topdir, ".Trash"), 0o777|os.ModeSticky)
+		}, filepath.Join(".Trash", uid)},
+		{"shared trash without sticky bit", func(topdir string) error {
+			return os.Mkdir(filepath.Join(topdir, ".Trash"), 0o777)
+		}, ".Trash-" + uid},
+		{"shared trash is a symlink", func(topdir string) error {
+			elsewhere := filepath.Join(topdir, "elsewhere")
+			if err := os.Mkdir(elsewhere, 0o777); err != nil {
+				return err
+			}
+			if err := os.Chmod(elsewhere, 0o777|os.ModeSticky); err != nil {
+				return err
+			}
+			return os.Symlink(elsewhere, filepath.Join(topdir, ".Trash"))
+		}, ".Trash-" + uid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topdir := t.TempDir()
+			if err := tt.setup(topdir); err != nil {
+				t.Fatal(err)
+			}
+			dir, err := trash.volumeTrash(topdir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(topdir, tt.want); dir != want {
+				t.Fatalf("expected %s, got %s", want, dir)
+			}
+			if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+				t.Fatalf("trash not created: %v", err)
+			}
+		})
+	}
+
+	// A file where the per-user trash should be can't be used
+	topdir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(topdir, ".Trash-"+uid), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := trash.volumeTrash(topdir); err == nil {
+		t.Fatal("expected an error for an unusable trash")
+	}
+}
+
+func TestEscapeTrashPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/home/user/app/node_modules", "/home/user/app/node_modules"},
+		{"/home/user/my app/node_modules", "/home/user/my%20app/node_modules"},
+		{"/srv/100%/node_modules", "/srv/100%25/node_modules"},
+		{"/srv/projet-été/node_modules", "/srv/projet-%C3%A9t%C3%A9/node_modules"},
+		{"/srv/a?b#c/node_modules", "/srv/a%3Fb%23c/node_modules"},
+		{"app/node_modules", "app/node_modules"},
+	}
+	for _, tt := range tests {
+		if got := escapeTrashPath(tt.in); got != tt.want {
+			t.Errorf("escapeTrashPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package deleter
+
+import "github.com/riadafridishibly/npmclean/scanner"
+
+// Trash is only implemented for the freedesktop.org spec on Linux
+type Trash struct{}
+
+func NewTrash() (*Trash, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *Trash) Name() string {
+	return StrategyTrash
+}
+
+func (t *Trash) Delete(*scanner.NodeModuleInfo) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+
+package deleter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+func deviceOf(path string) (uint64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(info.Sys().(*syscall.Stat_t).Dev), nil
+}
+
+// mountPoint returns the top directory of the filesystem path lives on
+func mountPoint(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dev, err := deviceOf(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		pdev, err := deviceOf(parent)
+		if err != nil || pdev != dev {
+			return path, nil
+		}
+		path = parent
+	}
+}
+
+func sameVolume(a, b string) bool {
+	da, err := deviceOf(a)
+	if err != nil {
+		return false
+	}
+	db, err := deviceOf(b)
+	return err == nil && da == db
+}
+
+// crossDevice reports whether a rename failed because it would cross
+// filesystems
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,36 @@
+//go:build windows
+
+package deleter
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// mountPoint returns the volume (drive letter or UNC share) of path
+func mountPoint(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.VolumeName(path) + string(filepath.Separator), nil
+}
+
+func sameVolume(a, b string) bool {
+	va, err := mountPoint(a)
+	if err != nil {
+		return false
+	}
+	vb, err := mountPoint(b)
+	return err == nil && strings.EqualFold(va, vb)
+}
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE
+const errorNotSameDevice = syscall.Errno(17)
+
+// crossDevice reports whether a rename failed because it would cross volumes
+func crossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
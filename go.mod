@@ -7,6 +7,10 @@ require (
 	github.com/charlievieth/fastwalk v1.0.14
 	github.com/dustin/go-humanize v1.0.1
 	github.com/gdamore/tcell/v3 v3.0.4
+	github.com/klauspost/compress v1.20.1
+	golang.org/x/sys v0.39.0
+	golang.org/x/term v0.38.0
+	modernc.org/sqlite v1.44.3
 )
 
 require (
@@ -22,11 +26,8 @@ require (
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
-	golang.org/x/sys v0.39.0 // indirect
-	golang.org/x/term v0.38.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 	modernc.org/libc v1.67.6 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.44.3 // indirect
 )
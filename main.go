@@ -7,7 +7,8 @@ import (
 	"path/filepath"
 	"runtime"
 
-	"github.com/riadafridishibly/npmclean/tui"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/config"
 )
 
 func tempDir() string {
@@ -17,8 +18,12 @@ func tempDir() string {
 	return os.TempDir()
 }
 
+func logPath() string {
+	return filepath.Join(tempDir(), "npmclean.log")
+}
+
 func main() {
-	logFile, err := os.Create(filepath.Join(tempDir(), "npmclean.log"))
+	logFile, err := os.Create(logPath())
 	if err != nil {
 		log.Fatalf("Error creating log file: %v", err)
 	}
@@ -26,43 +31,29 @@ func main() {
 	log.SetPrefix("[NPMCLN] ")
 	log.SetOutput(logFile)
 
-	fmt.Println("Logfile is being written in:", logFile.Name())
-
-	var rootDir string
 	if len(os.Args) > 1 {
-		rootDir = os.Args[1]
-	} else {
-		cwd, err := os.Getwd()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
-			os.Exit(1)
+		switch arg := os.Args[1]; arg {
+		case "help", "-h", "-help", "--help":
+			printCommands()
+			return
+		default:
+			if cmd, ok := commands[arg]; ok {
+				os.Exit(cmd.run(os.Args[2:]))
+			}
 		}
-		rootDir = cwd
 	}
+	// Without a command, like before there were any
+	os.Exit(runTUI(os.Args[1:]))
+}
 
-	absPath, err := filepath.Abs(rootDir)
+// openStore opens the cache backend, falling back to an in-memory store so the
+// TUI keeps working when the cache can't be opened
+func openStore(cf *cacheFlags, cfg *config.Config) cache.Store {
+	store, err := cf.open(cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error resolving path %s: %v\n", rootDir, err)
-		os.Exit(1)
-	}
-
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Path does not exist: %s\n", absPath)
-		os.Exit(1)
-	}
-
-	for {
-		app := tui.NewApp(absPath)
-		if err := app.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error running application: %v\n", err)
-			os.Exit(1)
-		}
-		// Close scanner to clean up cache
-		if app.Scanner() != nil {
-			app.Scanner().Close()
-		}
-		if !app.ShouldRestart() {
-			break
-		}
+		log.Printf("Failed to initialize cache: %v", err)
+		fmt.Fprintf(os.Stderr, "Cache disabled: %v\n", err)
+		return cache.NewMemoryStore()
 	}
+	return store
 }
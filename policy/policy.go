@@ -0,0 +1,145 @@
+// Package policy evaluates the cleanup rules from the config file against
+// scan results
+package policy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/config"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// Policy is a set of rules, a tree matching any of them is up for cleanup
+type Policy struct {
+	rules []rule
+}
+
+type rule struct {
+	config.Rule
+	name string
+	// Under and Except made absolute
+	under, except []string
+}
+
+// Match is a tree selected by a rule
+type Match struct {
+	Module *scanner.NodeModuleInfo
+	Rule   string
+}
+
+// New resolves the paths in rules, a rule without any condition is an error
+// as it would match everything
+func New(rules []config.Rule) (*Policy, error) {
+	p := &Policy{}
+	for i, r := range rules {
+		if r.OlderThan <= 0 && r.LargerThan <= 0 && len(r.Under) == 0 {
+			return nil, fmt.Errorf("rule %d matches everything, give it older_than, larger_than or under", i+1)
+		}
+		compiled := rule{Rule: r, name: r.Name}
+		if compiled.name == "" {
+			compiled.name = Describe(r)
+		}
+		var err error
+		if compiled.under, err = absPaths(r.Under); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", compiled.name, err)
+		}
+		if compiled.except, err = absPaths(r.Except); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", compiled.name, err)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+func absPaths(paths []string) ([]string, error) {
+	abs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		a, err := config.AbsPath(p)
+		if err != nil {
+			return nil, err
+		}
+		abs = append(abs, a)
+	}
+	return abs, nil
+}
+
+// Empty reports whether there are no rules
+func (p *Policy) Empty() bool {
+	return p == nil || len(p.rules) == 0
+}
+
+// Match returns the name of the first rule module matches at now, or ""
+func (p *Policy) Match(module *scanner.NodeModuleInfo, now time.Time) string {
+	if p == nil {
+		return ""
+	}
+	for _, r := range p.rules {
+		if r.matches(module, now) {
+			return r.name
+		}
+	}
+	return ""
+}
+
+// Select returns the modules matching a rule at now, in the given order
+func (p *Policy) Select(modules []*scanner.NodeModuleInfo, now time.Time) []Match {
+	var matches []Match
+	for _, module := range modules {
+		if name := p.Match(module, now); name != "" {
+			matches = append(matches, Match{Module: module, Rule: name})
+		}
+	}
+	return matches
+}
+
+func (r *rule) matches(module *scanner.NodeModuleInfo, now time.Time) bool {
+	if r.OlderThan > 0 && now.Sub(module.LastModifiedAt) < time.Duration(r.OlderThan) {
+		return false
+	}
+	if r.LargerThan > 0 && module.Size < int64(r.LargerThan) {
+		return false
+	}
+	if len(r.under) > 0 && !underAny(module.Path, r.under) {
+		return false
+	}
+	return !underAny(module.Path, r.except)
+}
+
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		sep := string(filepath.Separator)
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, sep)+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe spells out the conditions of r
+func Describe(r config.Rule) string {
+	var parts []string
+	if r.OlderThan > 0 {
+		parts = append(parts, "not modified in "+formatAge(time.Duration(r.OlderThan)))
+	}
+	if r.LargerThan > 0 {
+		parts = append(parts, "larger than "+humanize.Bytes(uint64(r.LargerThan)))
+	}
+	if len(r.Under) > 0 {
+		parts = append(parts, "under "+strings.Join(r.Under, ", "))
+	}
+	if len(r.Except) > 0 {
+		parts = append(parts, "except under "+strings.Join(r.Except, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatAge(d time.Duration) string {
+	if day := 24 * time.Hour; d >= day && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
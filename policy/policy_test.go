@@ -0,0 +1,48 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/config"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+func TestPolicy(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	p, err := New([]config.Rule{
+		{
+			OlderThan:  config.Duration(60 * day),
+			LargerThan: 200_000_000,
+			Except:     []string{"/work/prod"},
+		},
+		{Name: "scratch", Under: []string{"/tmp/scratch"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		age  time.Duration
+		size int64
+		want string
+	}{
+		{"/work/app/node_modules", 90 * day, 300_000_000, "not modified in 60d, larger than 200 MB, except under /work/prod"},
+		{"/work/app/node_modules", 30 * day, 300_000_000, ""},
+		{"/work/app/node_modules", 90 * day, 100_000_000, ""},
+		{"/work/prod/api/node_modules", 90 * day, 300_000_000, ""},
+		{"/work/production/node_modules", 90 * day, 300_000_000, "not modified in 60d, larger than 200 MB, except under /work/prod"},
+		{"/tmp/scratch/x/node_modules", 0, 1, "scratch"},
+	} {
+		module := &scanner.NodeModuleInfo{Path: tc.path, Size: tc.size, LastModifiedAt: now.Add(-tc.age)}
+		if got := p.Match(module, now); got != tc.want {
+			t.Errorf("%s (%v old, %d bytes): got rule %q, want %q", tc.path, tc.age, tc.size, got, tc.want)
+		}
+	}
+
+	if _, err := New([]config.Rule{{Except: []string{"/work"}}}); err == nil {
+		t.Fatal("a rule without conditions should be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// InstallCommand is the command bringing node_modules back exactly as the
+// lockfile describes it. Without a known package manager it falls back to
+// npm install, as there may be no lockfile either.
+func InstallCommand(pm PackageManager) []string {
+	switch pm {
+	case NPM:
+		return []string{"npm", "ci"}
+	case Yarn:
+		return []string{"yarn", "install", "--immutable"}
+	case PNPM:
+		return []string{"pnpm", "install", "--frozen-lockfile"}
+	case Bun:
+		return []string{"bun", "install"}
+	default:
+		return []string{"npm", "install"}
+	}
+}
+
+// CommandFor is the configured install command override when there is one,
+// the InstallCommand of pm otherwise
+func CommandFor(pm PackageManager, override []string) []string {
+	if len(override) > 0 {
+		return override
+	}
+	return InstallCommand(pm)
+}
+
+// Install runs command in the project directory, or the InstallCommand of
+// its package manager when command is empty, writing all output to out
+func Install(ctx context.Context, info Info, command []string, out io.Writer) error {
+	command = CommandFor(info.PackageManager, command)
+	fmt.Fprintf(out, "$ %s\n", strings.Join(command, " "))
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = info.Dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestInstallCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		pm      PackageManager
+		command []string
+	}{
+		{"npm lockfile", map[string]string{"package-lock.json": "{}"}, NPM, []string{"npm", "ci"}},
+		{"npm shrinkwrap", map[string]string{"npm-shrinkwrap.json": "{}"}, NPM, []string{"npm", "ci"}},
+		{"yarn lockfile", map[string]string{"yarn.lock": ""}, Yarn, []string{"yarn", "install", "--immutable"}},
+		{"pnpm lockfile", map[string]string{"pnpm-lock.yaml": ""}, PNPM, []string{"pnpm", "install", "--frozen-lockfile"}},
+		{"bun text lockfile", map[string]string{"bun.lock": ""}, Bun, []string{"bun", "install"}},
+		{"bun binary lockfile", map[string]string{"bun.lockb": ""}, Bun, []string{"bun", "install"}},
+		{"no lockfile", map[string]string{"package.json": `{"name": "app"}`}, Unknown, []string{"npm", "install"}},
+		{"nothing at all", nil, Unknown, []string{"npm", "install"}},
+		{"pnpm lockfile wins over npm", map[string]string{"pnpm-lock.yaml": "", "package-lock.json": "{}"}, PNPM, []string{"pnpm", "install", "--frozen-lockfile"}},
+		{"packageManager wins over lockfile", map[string]string{
+			"package.json":      `{"packageManager": "yarn@4.1.0"}`,
+			"package-lock.json": "{}",
+		}, Yarn, []string{"yarn", "install", "--immutable"}},
+		{"unknown packageManager falls back to lockfile", map[string]string{
+			"package.json": `{"packageManager": "deno@2.0.0"}`,
+			"bun.lock":     "",
+		}, Bun, []string{"bun", "install"}},
+		{"broken package.json", map[string]string{"package.json": "{", "yarn.lock": ""}, Yarn, []string{"yarn", "install", "--immutable"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "app")
+			if err := os.MkdirAll(filepath.Join(dir, "node_modules"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info := Detect(filepath.Join(dir, "node_modules"))
+			if info.PackageManager != tt.pm {
+				t.Fatalf("expected package manager %q, got %q", tt.pm, info.PackageManager)
+			}
+			if got := InstallCommand(info.PackageManager); !slices.Equal(got, tt.command) {
+				t.Fatalf("expected %v, got %v", tt.command, got)
+			}
+		})
+	}
+}
+
+func TestCommandFor(t *testing.T) {
+	override := []string{"npm", "install", "--no-audit"}
+	if got := CommandFor(PNPM, override); !slices.Equal(got, override) {
+		t.Errorf("expected the override %v, got %v", override, got)
+	}
+	for _, empty := range [][]string{nil, {}} {
+		if got := CommandFor(PNPM, empty); !slices.Equal(got, InstallCommand(PNPM)) {
+			t.Errorf("expected the pnpm default without an override, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package project
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type PackageManager string
+
+const (
+	Unknown PackageManager = ""
+	NPM     PackageManager = "npm"
+	Yarn    PackageManager = "yarn"
+	PNPM    PackageManager = "pnpm"
+	Bun     PackageManager = "bun"
+)
+
+// Info describes the project owning a node_modules directory
+type Info struct {
+	Dir            string
+	Name           string
+	PackageManager PackageManager
+}
+
+// Lockfiles in the order we check them, the first one found wins
+var lockfiles = []struct {
+	name string
+	pm   PackageManager
+}{
+	{"pnpm-lock.yaml", PNPM},
+	{"yarn.lock", Yarn},
+	{"bun.lock", Bun},
+	{"bun.lockb", Bun},
+	{"package-lock.json", NPM},
+	{"npm-shrinkwrap.json", NPM},
+}
+
+type packageJSON struct {
+	Name           string `json:"name"`
+	PackageManager string `json:"packageManager"`
+}
+
+// Detect inspects the parent of nodeModulesPath and figures out the project
+// name and the package manager used to install it. It never fails, missing
+// information is left empty (name falls back to the directory name).
+func Detect(nodeModulesPath string) Info {
+	dir := filepath.Dir(nodeModulesPath)
+	info := Info{Dir: dir, Name: filepath.Base(dir)}
+
+	var pkg packageJSON
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		if err := json.Unmarshal(data, &pkg); err == nil && pkg.Name != "" {
+			info.Name = pkg.Name
+		}
+	}
+
+	// corepack style "packageManager": "pnpm@9.1.0" is the most explicit hint
+	if name, _, _ := strings.Cut(pkg.PackageManager, "@"); name != "" {
+		switch pm := PackageManager(name); pm {
+		case NPM, Yarn, PNPM, Bun:
+			info.PackageManager = pm
+			return info
+		}
+	}
+
+	for _, lf := range lockfiles {
+		if _, err := os.Stat(filepath.Join(dir, lf.name)); err == nil {
+			info.PackageManager = lf.pm
+			return info
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,38 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvWriter writes a row per item grouped by root, each group followed by a
+// subtotal row and the whole report by a total row. The kind column tells
+// them apart for spreadsheets.
+type csvWriter struct {
+	w     io.Writer
+	items []Item
+}
+
+func (c *csvWriter) WriteItem(item Item) error {
+	c.items = append(c.items, item)
+	return nil
+}
+
+func (c *csvWriter) WriteSummary(s Summary) error {
+	w := csv.NewWriter(c.w)
+	w.Write([]string{"kind", "root", "path", "project", "package_manager", "size", "last_modified_at", "items"})
+	for _, g := range groupByRoot(c.items, s.Roots) {
+		for _, item := range g.items {
+			w.Write([]string{
+				"item", item.Root, item.Path, item.ProjectName, item.PackageManager,
+				strconv.FormatInt(item.Size, 10), item.LastModifiedAt.Format(time.RFC3339), "1",
+			})
+		}
+		w.Write([]string{"subtotal", g.root, "", "", "", strconv.FormatInt(g.size, 10), "", strconv.Itoa(len(g.items))})
+	}
+	w.Write([]string{"total", "", "", "", "", strconv.FormatInt(s.Size, 10), "", strconv.Itoa(s.Items)})
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type jsonSummary struct {
+	Roots        []string `json:"roots"`
+	Items        int      `json:"items"`
+	Size         int64    `json:"size"`
+	ScannedItems int      `json:"scanned_items"`
+	ScannedSize  int64    `json:"scanned_size"`
+	Files        int64    `json:"files"`
+	ElapsedMS    int64    `json:"elapsed_ms"`
+}
+
+func newJSONSummary(s Summary) jsonSummary {
+	return jsonSummary{
+		Roots:        s.Roots,
+		Items:        s.Items,
+		Size:         s.Size,
+		ScannedItems: s.ScannedItems,
+		ScannedSize:  s.ScannedSize,
+		Files:        s.Files,
+		ElapsedMS:    s.Elapsed.Milliseconds(),
+	}
+}
+
+// jsonWriter writes one document, {"items": [...], "summary": {...}}, with
+// every item on its own line so it can still be followed as it grows
+type jsonWriter struct {
+	w     io.Writer
+	items int
+}
+
+func (j *jsonWriter) WriteItem(item Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if j.items == 0 {
+		sep = "{\"items\": [\n  "
+	}
+	j.items++
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) WriteSummary(s Summary) error {
+	data, err := json.Marshal(newJSONSummary(s))
+	if err != nil {
+		return err
+	}
+	head := "\n],\n"
+	if j.items == 0 {
+		head = "{\"items\": [],\n"
+	}
+	if _, err := io.WriteString(j.w, head+"\"summary\": "); err != nil {
+		return err
+	}
+	if _, err := j.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(j.w, "}\n")
+	return err
+}
+
+// jsonlWriter writes an object per line, {"type": "item", ...} for every
+// item and {"type": "summary", ...} last
+type jsonlWriter struct {
+	w io.Writer
+}
+
+func (j *jsonlWriter) WriteItem(item Item) error {
+	return j.line(struct {
+		Type string `json:"type"`
+		Item
+	}{"item", item})
+}
+
+func (j *jsonlWriter) WriteSummary(s Summary) error {
+	return j.line(struct {
+		Type string `json:"type"`
+		jsonSummary
+	}{"summary", newJSONSummary(s)})
+}
+
+func (j *jsonlWriter) line(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// markdownWriter writes a table per root with its subtotal and the totals
+// last, ready to paste into a wiki page or an issue
+type markdownWriter struct {
+	w     io.Writer
+	items []Item
+}
+
+func (m *markdownWriter) WriteItem(item Item) error {
+	m.items = append(m.items, item)
+	return nil
+}
+
+func (m *markdownWriter) WriteSummary(s Summary) error {
+	w := bufio.NewWriter(m.w)
+	fmt.Fprintf(w, "# node_modules report\n\n")
+	fmt.Fprintf(w, "%s in %s", trees(s.Items), humanize.Bytes(uint64(s.Size)))
+	if s.ScannedItems != s.Items {
+		fmt.Fprintf(w, ", out of %s in %s", trees(s.ScannedItems), humanize.Bytes(uint64(s.ScannedSize)))
+	}
+	fmt.Fprintf(w, " found by scanning %s files in %s.\n", humanize.Comma(s.Files), s.Elapsed.Round(time.Millisecond))
+
+	for _, g := range groupByRoot(m.items, s.Roots) {
+		fmt.Fprintf(w, "\n## %s\n\n", cell(g.root))
+		if len(g.items) == 0 {
+			fmt.Fprintln(w, "Nothing found.")
+			continue
+		}
+		fmt.Fprintln(w, "| Size | Last modified | Package manager | Project | Path |")
+		fmt.Fprintln(w, "| ---: | --- | --- | --- | --- |")
+		for _, item := range g.items {
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n",
+				humanize.Bytes(uint64(item.Size)), item.LastModifiedAt.Format(time.DateOnly),
+				cell(item.PackageManager), cell(item.ProjectName), cell(item.Path))
+		}
+		fmt.Fprintf(w, "| **%s** | | | **Subtotal** | %s |\n", humanize.Bytes(uint64(g.size)), trees(len(g.items)))
+	}
+
+	fmt.Fprintf(w, "\n**Total: %s in %s**\n", trees(s.Items), humanize.Bytes(uint64(s.Size)))
+	return w.Flush()
+}
+
+func trees(n int) string {
+	if n == 1 {
+		return "1 tree"
+	}
+	return fmt.Sprintf("%d trees", n)
+}
+
+// cell escapes what would break a table cell
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,120 @@
+// Package report writes scan results as JSON, CSV or Markdown
+package report
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatJSONL    = "jsonl"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+var Formats = []string{FormatJSON, FormatJSONL, FormatCSV, FormatMarkdown}
+
+// Streams reports whether format writes items as they come, the others
+// hold them back for subtotals
+func Streams(format string) bool {
+	return format == FormatJSON || format == FormatJSONL
+}
+
+// Item is a scanned tree with what is known about its project
+type Item struct {
+	Path           string    `json:"path"`
+	Root           string    `json:"root"`
+	Size           int64     `json:"size"`
+	LastModifiedAt time.Time `json:"last_modified_at"`
+	ScannedAt      time.Time `json:"scanned_at"`
+	ProjectDir     string    `json:"project_dir"`
+	ProjectName    string    `json:"project_name"`
+	PackageManager string    `json:"package_manager"`
+}
+
+// NewItem describes module, found by scanning root
+func NewItem(module *scanner.NodeModuleInfo, root string) Item {
+	proj := project.Detect(module.Path)
+	return Item{
+		Path:           module.Path,
+		Root:           root,
+		Size:           module.Size,
+		LastModifiedAt: module.LastModifiedAt,
+		ScannedAt:      module.ScannedAt,
+		ProjectDir:     proj.Dir,
+		ProjectName:    proj.Name,
+		PackageManager: string(proj.PackageManager),
+	}
+}
+
+// Summary sums up the items written and the scan they came from
+type Summary struct {
+	Roots []string
+	Items int
+	Size  int64
+	// Everything the scan found, filtered out items included
+	ScannedItems int
+	ScannedSize  int64
+	Files        int64
+	Elapsed      time.Duration
+}
+
+// Writer writes the items one by one as they are found and the summary last
+type Writer interface {
+	WriteItem(Item) error
+	WriteSummary(Summary) error
+}
+
+// NewWriter returns a writer for format
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatJSONL:
+		return &jsonlWriter{w: w}, nil
+	case FormatCSV:
+		return &csvWriter{w: w}, nil
+	case FormatMarkdown:
+		return &markdownWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (want one of %v)", format, Formats)
+}
+
+// group is the items found under one root
+type group struct {
+	root  string
+	items []Item
+	size  int64
+}
+
+// groupByRoot splits items by root in the order of roots, keeping the order
+// of the items within each root
+func groupByRoot(items []Item, roots []string) []*group {
+	groups := make([]*group, 0, len(roots))
+	byRoot := make(map[string]*group, len(roots))
+	add := func(root string) *group {
+		g := &group{root: root}
+		groups = append(groups, g)
+		byRoot[root] = g
+		return g
+	}
+	for _, root := range roots {
+		if byRoot[root] == nil {
+			add(root)
+		}
+	}
+	for _, item := range items {
+		g := byRoot[item.Root]
+		if g == nil {
+			g = add(item.Root)
+		}
+		g.items = append(g.items, item)
+		g.size += item.Size
+	}
+	return groups
+}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSON(t *testing.T) {
+	items := []Item{
+		{Path: "/work/a/node_modules", Root: "/work", Size: 300, PackageManager: "npm"},
+		{Path: "/work/b/node_modules", Root: "/work", Size: 200},
+	}
+	summary := Summary{Roots: []string{"/work"}, Items: 2, Size: 500, ScannedItems: 3, ScannedSize: 600, Files: 42, Elapsed: 1500 * time.Millisecond}
+
+	for _, n := range []int{0, len(items)} {
+		var buf bytes.Buffer
+		w, err := NewWriter(FormatJSON, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items[:n] {
+			if err := w.WriteItem(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.WriteSummary(summary); err != nil {
+			t.Fatal(err)
+		}
+
+		var doc struct {
+			Items   []Item      `json:"items"`
+			Summary jsonSummary `json:"summary"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("%d items: %v\n%s", n, err, buf.String())
+		}
+		if len(doc.Items) != n || doc.Items == nil {
+			t.Errorf("%d items: got %v", n, doc.Items)
+		}
+		if doc.Summary.ElapsedMS != 1500 || doc.Summary.Files != 42 || doc.Summary.ScannedItems != 3 {
+			t.Errorf("%d items: got summary %+v", n, doc.Summary)
+		}
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatJSONL, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteItem(Item{Path: "/work/a/node_modules", Size: 300}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteSummary(Summary{Items: 1, Size: 300}); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var line struct {
+			Type string `json:"type"`
+			Path string `json:"path"`
+			Size int64  `json:"size"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("%v: %s", err, sc.Text())
+		}
+		if line.Size != 300 {
+			t.Errorf("%s line has size %d, want 300", line.Type, line.Size)
+		}
+		types = append(types, line.Type)
+	}
+	if len(types) != 2 || types[0] != "item" || types[1] != "summary" {
+		t.Errorf("got lines %v, want an item and the summary", types)
+	}
+}
+
+func TestSubtotals(t *testing.T) {
+	items := []Item{
+		{Path: "/work/a/node_modules", Root: "/work", Size: 300, ProjectName: "a|b"},
+		{Path: "/src/c/node_modules", Root: "/src", Size: 100},
+		{Path: "/work/b/node_modules", Root: "/work", Size: 200},
+	}
+	summary := Summary{Roots: []string{"/work", "/src", "/empty"}, Items: 3, Size: 600}
+
+	write := func(format string) string {
+		var buf bytes.Buffer
+		w, err := NewWriter(format, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			if err := w.WriteItem(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.WriteSummary(summary); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	records, err := csv.NewReader(strings.NewReader(write(FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range records[1:] {
+		got = append(got, r[0]+" "+r[1]+" "+r[2]+" "+r[5])
+	}
+	want := []string{
+		"item /work /work/a/node_modules 300",
+		"item /work /work/b/node_modules 200",
+		"subtotal /work  500",
+		"item /src /src/c/node_modules 100",
+		"subtotal /src  100",
+		"subtotal /empty  0",
+		"total   600",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got rows\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	md := write(FormatMarkdown)
+	for _, want := range []string{"## /work\n", "| **500 B** | | | **Subtotal** | 2 trees |", `| a\|b |`, "## /empty\n\nNothing found.", "**Total: 3 trees in 600 B**"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, md)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"log"
 	"os"
 	"runtime"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -55,19 +54,27 @@ type Scanner struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	// Cache for storing/retrieving node_modules info
-	cache *cache.Cache
+	// Cache for storing/retrieving node_modules info, nil disables caching
+	cache cache.Store
+
+	// Batches cache writes of freshly calculated sizes
+	writer *cache.BatchWriter
 
 	// Set of paths that have already been processed (from cache or scan)
 	acceptedCachePaths sync.Map
+
+	// Totals of everything found (from cache or scan), recorded in the session
+	entryCount atomic.Int64
+	totalSize  atomic.Int64
 }
 
-func NewScanner(rootPath string) *Scanner {
+// NewScanner creates a scanner for rootPath. The store is owned by the caller
+// and is not closed by the scanner, pass nil to scan without cache.
+func NewScanner(rootPath string, store cache.Store) *Scanner {
 	ctx, cancel := context.WithCancel(context.Background())
-	c, err := cache.NewCache()
-	if err != nil {
-		log.Printf("Failed to initialize cache: %v", err)
-		c = nil
+	var w *cache.BatchWriter
+	if store != nil {
+		w = cache.NewBatchWriter(store, cache.DefaultBatchSize, cache.DefaultFlushInterval)
 	}
 	return &Scanner{
 		rootPath:  rootPath,
@@ -79,7 +86,8 @@ func NewScanner(rootPath string) *Scanner {
 		startTime: time.Now(),
 		ctx:       ctx,
 		cancel:    cancel,
-		cache:     c,
+		cache:     store,
+		writer:    w,
 	}
 }
 
@@ -91,6 +99,13 @@ func (s *Scanner) Start() {
 	s.fileCount = 0
 	// Reset context for new scan
 	s.ctx, s.cancel = context.WithCancel(context.Background())
+	var session *cache.Session
+	if s.cache != nil {
+		var err error
+		if session, err = s.cache.StartSession(s.rootPath); err != nil {
+			log.Printf("Failed to start scan session: %v", err)
+		}
+	}
 	go func() {
 		defer close(s.doneChan) // We're done when result is done
 		defer close(s.progress)
@@ -98,7 +113,23 @@ func (s *Scanner) Start() {
 
 		s.scan()
 
+		if s.writer != nil {
+			if err := s.writer.Flush(); err != nil {
+				log.Printf("Failed to flush cache writes: %v", err)
+			}
+		}
+
 		s.elapsedTime.Store(time.Since(s.startTime).Milliseconds())
+
+		// Aborted scans keep a zero FinishedAt
+		if session != nil && s.ctx.Err() == nil {
+			session.Entries = s.entryCount.Load()
+			session.Bytes = s.totalSize.Load()
+			session.FileCount = s.FileCount()
+			if err := s.cache.FinishSession(session); err != nil {
+				log.Printf("Failed to finish scan session: %v", err)
+			}
+		}
 	}()
 }
 
@@ -148,13 +179,13 @@ func (s *Scanner) ElapsedTime() time.Duration {
 }
 
 func (s *Scanner) Close() error {
-	if s.cache != nil {
-		return s.cache.Close()
+	if s.writer != nil {
+		return s.writer.Close()
 	}
 	return nil
 }
 
-func (s *Scanner) Cache() *cache.Cache {
+func (s *Scanner) Cache() cache.Store {
 	return s.cache
 }
 
@@ -164,29 +195,21 @@ func (s *Scanner) LoadCachedResults() ([]*NodeModuleInfo, error) {
 		return nil, nil
 	}
 
-	entries, err := s.cache.GetAll()
-	if err != nil {
-		return nil, err
-	}
-
 	var results []*NodeModuleInfo
-	for _, entry := range entries {
-		// Only load entries under our root path
-		if !strings.HasPrefix(entry.Path, s.rootPath) {
-			continue
-		}
+	// Only load entries under our root path
+	err := s.cache.RangePrefix(s.rootPath, func(entry *cache.CacheEntry) bool {
 		// Check if path still exists
 		if _, err := os.Stat(entry.Path); os.IsNotExist(err) {
 			// Path doesn't exist, remove from cache
 			s.cache.Delete(entry.Path)
-			continue
+			return true
 		}
 
 		// Check if modification time matches
 		currentModTime, err := GetLastModifiedAt(entry.Path)
 		if err != nil {
 			// If we can't get mod time, skip this entry
-			continue
+			return true
 		}
 
 		ct := currentModTime.Truncate(time.Second)
@@ -197,9 +220,20 @@ func (s *Scanner) LoadCachedResults() ([]*NodeModuleInfo, error) {
 				Path:           entry.Path,
 				Size:           entry.Size,
 				LastModifiedAt: entry.LastModifiedAt,
-				ScannedAt:      entry.ScannedAt,
+				ScannedAt:      time.Now(),
+			}
+			// Finding the tree again counts as a scan, otherwise pruning by
+			// age drops trees that are alive but never change
+			if s.writer != nil {
+				seen := *entry
+				seen.ScannedAt = info.ScannedAt
+				if err := s.writer.Put(&seen); err != nil {
+					log.Printf("Failed to refresh entry: %q: %v", entry.Path, err)
+				}
 			}
 			results = append(results, info)
+			s.entryCount.Add(1)
+			s.totalSize.Add(entry.Size)
 			// Mark as processed to avoid recalculating during scan
 			s.acceptedCachePaths.Store(entry.Path, true)
 		} else {
@@ -207,9 +241,10 @@ func (s *Scanner) LoadCachedResults() ([]*NodeModuleInfo, error) {
 			// Remove outdated entry
 			s.cache.Delete(entry.Path)
 		}
-	}
+		return true
+	})
 
-	return results, nil
+	return results, err
 }
 
 func (s *Scanner) calculateSize(path string) {
@@ -225,6 +260,8 @@ func (s *Scanner) calculateSize(path string) {
 	}
 
 	fileCount := atomic.AddInt64(&s.fileCount, result.FilesScanned)
+	s.entryCount.Add(1)
+	s.totalSize.Add(result.Size)
 
 	lastModified, err := GetLastModifiedAt(path)
 	if err != nil {
@@ -238,15 +275,15 @@ func (s *Scanner) calculateSize(path string) {
 		ScannedAt:      time.Now(),
 	}
 
-	// Update cache if available
-	if s.cache != nil {
+	// Update cache if available, writes are batched into transactions
+	if s.writer != nil {
 		cacheEntry := &cache.CacheEntry{
 			Path:           path,
 			Size:           result.Size,
 			LastModifiedAt: lastModified,
 			ScannedAt:      info.ScannedAt,
 		}
-		if err := s.cache.InsertOrUpdate(cacheEntry); err != nil {
+		if err := s.writer.Put(cacheEntry); err != nil {
 			log.Printf("Failed to insert entry: %q: %v", path, err)
 		}
 	}
@@ -1,40 +1,56 @@
 package scanner
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/riadafridishibly/npmclean/cache"
 )
 
-func TestScanner(t *testing.T) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
+// makeTree lays out a few projects under root and returns the node_modules
+// directories a scan should find
+func makeTree(t *testing.T, root string) []string {
+	t.Helper()
+	files := []string{
+		"app/package.json",
+		"app/node_modules/left-pad/index.js",
+		// Nested trees belong to the outer one
+		"app/node_modules/left-pad/node_modules/is-even/index.js",
+		"libs/util/node_modules/is-odd/index.js",
+		"docs/README.md",
 	}
-	s := NewScanner(filepath.Join(home, "git-clones"))
-
-	// Load cached results
-	cached, err := s.LoadCachedResults()
-	if err != nil {
-		t.Logf("Error loading cached results: %v", err)
-	} else {
-		fmt.Printf("Loaded %d cached results\n", len(cached))
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, 1000), 0o644); err != nil {
+			t.Fatal(err)
+		}
 	}
-
-	// Also check what GetAll returns
-	if s.Cache() != nil {
-		all, _ := s.Cache().GetAll()
-		fmt.Printf("Cache has %d total entries\n", len(all))
+	return []string{
+		filepath.Join(root, "app", "node_modules"),
+		filepath.Join(root, "libs", "util", "node_modules"),
 	}
+}
 
-	wg := sync.WaitGroup{}
-	wg.Add(2) // Wait for both goroutines
+// runScan scans to completion and returns the paths found, sorted
+func runScan(t *testing.T, s *Scanner) []string {
+	t.Helper()
+	var paths []string
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		for res := range s.Results() {
-			fmt.Println(res.Size, res.Path)
+			if res.Size <= 0 {
+				t.Errorf("%s: expected a size, got %d", res.Path, res.Size)
+			}
+			paths = append(paths, res.Path)
 		}
 	}()
 	go func() {
@@ -46,5 +62,82 @@ func TestScanner(t *testing.T) {
 	s.Start()
 	<-s.Done()
 	wg.Wait()
-	s.Close()
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestScanner(t *testing.T) {
+	stores := map[string]func(t *testing.T) cache.Store{
+		"memory": func(*testing.T) cache.Store { return cache.NewMemoryStore() },
+		"sqlite": func(t *testing.T) cache.Store {
+			c, err := cache.Open(filepath.Join(t.TempDir(), "cache.db"))
+			if err != nil {
+				t.Fatalf("Error opening cache: %v", err)
+			}
+			return c
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			want := makeTree(t, root)
+			store := open(t)
+			defer store.Close()
+
+			if got := runScan(t, NewScanner(root, store)); !slices.Equal(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+			all, err := store.GetAll()
+			if err != nil || len(all) != len(want) {
+				t.Fatalf("expected %d cached entries, got %d %v", len(want), len(all), err)
+			}
+
+			// Pretend the first scan was long ago
+			for _, e := range all {
+				e.ScannedAt = time.Now().Add(-48 * time.Hour)
+			}
+			if err := store.InsertOrUpdateBatch(all); err != nil {
+				t.Fatal(err)
+			}
+
+			// A second scan takes the unchanged trees from the cache
+			s := NewScanner(root, store)
+			cached, err := s.LoadCachedResults()
+			if err != nil || len(cached) != len(want) {
+				t.Fatalf("expected %d cached results, got %d %v", len(want), len(cached), err)
+			}
+			if got := runScan(t, s); len(got) != 0 {
+				t.Fatalf("cached trees were measured again: %v", got)
+			}
+			// and counts as seeing them, pruning by age keeps them
+			pruned, err := cache.Prune(store, cache.PruneOptions{NotSeenFor: 24 * time.Hour})
+			if err != nil || len(pruned) != 0 {
+				t.Fatalf("expected nothing pruned, got %d %v", len(pruned), err)
+			}
+
+			// Trees that are gone are dropped from the cache
+			if err := os.RemoveAll(want[1]); err != nil {
+				t.Fatal(err)
+			}
+			s = NewScanner(root, store)
+			cached, err = s.LoadCachedResults()
+			if err != nil || len(cached) != 1 || cached[0].Path != want[0] {
+				t.Fatalf("expected only %s, got %v %v", want[0], cached, err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+		})
+	}
+}
+
+func TestScannerWithoutCache(t *testing.T) {
+	root := t.TempDir()
+	want := makeTree(t, root)
+	if got := runScan(t, NewScanner(root, nil)); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
 }
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -8,36 +9,88 @@ import (
 	"time"
 
 	"codeberg.org/tslocum/cview"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/config"
+	"github.com/riadafridishibly/npmclean/deleter"
+	"github.com/riadafridishibly/npmclean/policy"
 	"github.com/riadafridishibly/npmclean/scanner"
 )
 
 type App struct {
 	app     *cview.Application
 	scanner *scanner.Scanner
-
-	header       *cview.TextView
-	footer       *cview.TextView
-	table        *cview.Table
-	panels       *cview.Panels
-	detailModal  *cview.Modal
-	confirmModal *cview.Modal
-	themeModal   *cview.Modal
+	store   cache.Store
+	config  *config.Config
+	deleter deleter.Strategy
+	// relocator is nil unless cold storage is configured
+	relocator *deleter.Relocator
+	// policy holds the cleanup rules from the config file
+	policy *policy.Policy
+
+	layout     *cview.Flex
+	header     *cview.TextView
+	footer     *cview.TextView
+	table      *cview.Table
+	panels     *cview.Panels
+	queueTable *cview.Table
+	queuePanel *cview.Flex
+	// Output of the running or last reinstall
+	reinstallView  *cview.TextView
+	reinstallPanel *cview.Flex
+	detailModal    *cview.Modal
+	confirmModal   *cview.Modal
+	themeModal     *cview.Modal
+	historyModal   *cview.Modal
+	quitModal      *cview.Modal
+	reportModal    *cview.Modal
 
 	items       []*scanner.NodeModuleInfo
+	selected    map[string]bool // marked item paths
 	rootPath    string
 	lastUpdate  time.Time
 	showDetail  bool
 	showConfirm bool
 	showTheme   bool
+	showHistory bool
+	showQueue   bool
+	showQuit    bool
+	showReport  bool
+
+	showReinstall   bool
+	reinstallCancel context.CancelFunc
+
+	showTarget bool
+	// lastGoal is what was last asked for in the target form
+	lastGoal string
+
+	// configSaved is closed once the last config change is written, the next
+	// one waits for it so changes reach the file in order
+	configSaved chan struct{}
 
 	uiUpdates chan func()
 
+	// Items waiting for the confirm modal
+	pendingDelete   []*scanner.NodeModuleInfo
+	pendingStrategy deleter.Strategy
+	deletions       *deleter.Manager
+
 	userHomeDir        string
 	totalClaimableSize atomic.Int64
 
 	currentTheme  Theme
 	shouldRestart bool
 	isRestarting  atomic.Bool
+	// quitWhenIdle stops the app once the deletion queue drains
+	quitWhenIdle bool
+	forceQuit    bool
+
+	// A batch runs from the first deletion queued while the queue was idle
+	// until it drains again. Free space is sampled at both ends, so what was
+	// actually freed can be shown next to what the sizes promised.
+	batchActive    bool
+	batchFreeAt    int64
+	batchEstimated atomic.Int64
+	lastBatch      *spaceReport
 }
 
 func defaultTheme() Theme {
@@ -76,19 +129,44 @@ func (a *App) applyTheme() {
 	a.themeModal.SetButtonBackgroundColor(theme.buttonBg)
 	a.themeModal.SetButtonTextColor(theme.buttonFg)
 
+	a.historyModal.SetBackgroundColor(theme.modalBg)
+	a.historyModal.SetTextColor(theme.modalFg)
+	a.historyModal.SetButtonBackgroundColor(theme.buttonBg)
+	a.historyModal.SetButtonTextColor(theme.buttonFg)
+
+	a.quitModal.SetBackgroundColor(theme.modalBg)
+	a.quitModal.SetTextColor(theme.modalFg)
+	a.quitModal.SetButtonBackgroundColor(theme.buttonBg)
+	a.quitModal.SetButtonTextColor(theme.buttonFg)
+
+	a.reportModal.SetBackgroundColor(theme.modalBg)
+	a.reportModal.SetTextColor(theme.modalFg)
+	a.reportModal.SetButtonBackgroundColor(theme.buttonBg)
+	a.reportModal.SetButtonTextColor(theme.buttonFg)
+
 	a.table.SetBackgroundColor(theme.bg)
+	a.queueTable.SetBackgroundColor(theme.bg)
+	a.queueTable.SetBorderColor(theme.fg)
+	a.queueTable.SetTitleColor(theme.fg)
+	a.reinstallView.SetBackgroundColor(theme.bg)
+	a.reinstallView.SetTextColor(theme.fg)
+	a.reinstallView.SetBorderColor(theme.fg)
+	a.reinstallView.SetTitleColor(theme.fg)
 
 	a.panels.SetBackgroundColor(theme.bg)
 
 	a.trySendUIUpdate(func() {
-		a.header.SetText(headerStartupStatus(&theme, a.rootPath))
+		a.header.SetText(a.headerDryRun() + headerStartupStatus(&theme, a.rootPath))
 		a.footer.SetText(footerStatusMenu(&theme))
 		a.updateFinalStatus()
 		a.buildTable()
 	})
 }
 
-func NewApp(scanPath string) *App {
+// NewApp creates the TUI, deletions is owned by the caller so queued work can
+// outlive a restart and be waited for on exit
+func NewApp(scanPath string, store cache.Store, cfg *config.Config, deletions *deleter.Manager) *App {
+	strategy := deletions.Strategy()
 	app := cview.NewApplication()
 
 	theme := defaultTheme()
@@ -109,34 +187,88 @@ func NewApp(scanPath string) *App {
 
 	confirmModal := cview.NewModal()
 	confirmModal.SetText("")
-	confirmModal.AddButtons([]string{"Delete", "Cancel", "Don't ask again"})
+	confirmModal.AddButtons([]string{deleter.Verb(strategy), "Cancel", confirmSession, confirmNever})
 
 	themeModal := cview.NewModal()
 	themeModal.SetText("")
 	themeNames := getThemeNames()
 	themeModal.AddButtons(themeNames)
 
+	historyModal := cview.NewModal()
+	historyModal.SetText("")
+	historyModal.SetTextAlign(cview.AlignLeft)
+	historyModal.AddButtons([]string{"Okay"})
+
+	quitModal := cview.NewModal()
+	quitModal.SetText("")
+	quitModal.AddButtons([]string{quitWait, quitCancel, quitForce, quitBack})
+
+	reportModal := cview.NewModal()
+	reportModal.SetText("")
+	reportModal.SetTextAlign(cview.AlignLeft)
+	reportModal.AddButtons([]string{"Okay"})
+
 	panels := cview.NewPanels()
 	table := cview.NewTable()
 	panels.AddPanel("table", table, true, true)
 
+	queueTable := cview.NewTable()
+	queueTable.SetBorder(true)
+	queueTable.SetTitle(" Deletion queue ")
+	queueTable.SetSelectable(true, false)
+	queueTable.SetSeparator(' ')
+	queueHelp := cview.NewTextView()
+	queueHelp.SetTextAlign(cview.AlignCenter)
+	queueHelp.SetText("c: Cancel  C: Cancel all  r: Retry  n: Reinstall  x: Clear finished  w/Esc: Close")
+	queuePanel := cview.NewFlex()
+	queuePanel.SetDirection(cview.FlexRow)
+	queuePanel.AddItem(queueTable, 0, 1, true)
+	queuePanel.AddItem(queueHelp, 1, 0, false)
+
+	reinstallView := cview.NewTextView()
+	reinstallView.SetBorder(true)
+	reinstallView.SetDynamicColors(true)
+	reinstallView.SetScrollable(true)
+	reinstallHelp := cview.NewTextView()
+	reinstallHelp.SetTextAlign(cview.AlignCenter)
+	reinstallHelp.SetText("c: Cancel  n/Esc: Close (keeps running)")
+	reinstallPanel := cview.NewFlex()
+	reinstallPanel.SetDirection(cview.FlexRow)
+	reinstallPanel.AddItem(reinstallView, 0, 1, true)
+	reinstallPanel.AddItem(reinstallHelp, 1, 0, false)
+
 	a := &App{
-		app:           app,
-		header:        header,
-		footer:        footer,
-		detailModal:   detailModal,
-		confirmModal:  confirmModal,
-		themeModal:    themeModal,
-		rootPath:      scanPath,
-		panels:        panels,
-		table:         table,
-		items:         make([]*scanner.NodeModuleInfo, 0),
-		showDetail:    false,
-		showConfirm:   false,
-		showTheme:     false,
-		uiUpdates:     make(chan func(), 128),
-		currentTheme:  theme,
-		shouldRestart: false,
+		app:            app,
+		store:          store,
+		config:         cfg,
+		deleter:        strategy,
+		header:         header,
+		footer:         footer,
+		detailModal:    detailModal,
+		confirmModal:   confirmModal,
+		themeModal:     themeModal,
+		historyModal:   historyModal,
+		quitModal:      quitModal,
+		reportModal:    reportModal,
+		rootPath:       scanPath,
+		panels:         panels,
+		table:          table,
+		queueTable:     queueTable,
+		queuePanel:     queuePanel,
+		reinstallView:  reinstallView,
+		reinstallPanel: reinstallPanel,
+		items:          make([]*scanner.NodeModuleInfo, 0),
+		selected:       make(map[string]bool),
+		showDetail:     false,
+		showConfirm:    false,
+		showTheme:      false,
+		showHistory:    false,
+		showQuit:       false,
+		showReport:     false,
+		deletions:      deletions,
+		uiUpdates:      make(chan func(), 128),
+		currentTheme:   theme,
+		shouldRestart:  false,
 	}
 
 	flex := cview.NewFlex()
@@ -144,6 +276,22 @@ func NewApp(scanPath string) *App {
 	flex.AddItem(header, 1, 0, false)
 	flex.AddItem(panels, 0, 1, true)
 	flex.AddItem(footer, 1, 0, false)
+	a.layout = flex
+
+	deletions.SetOnUpdate(a.onDeleteUpdate)
+
+	if cfg.ColdStorageDir != "" {
+		if dir, err := config.AbsPath(cfg.ColdStorageDir); err != nil {
+			log.Printf("Ignoring cold_storage_dir: %v", err)
+		} else {
+			a.relocator = deleter.NewRelocator(dir)
+		}
+	}
+	if p, err := policy.New(cfg.Rules); err != nil {
+		log.Printf("Ignoring cleanup rules: %v", err)
+	} else {
+		a.policy = p
+	}
 
 	app.SetInputCapture(a.handleInput)
 
@@ -157,12 +305,48 @@ func NewApp(scanPath string) *App {
 		a.setRoot(flex, true)
 
 		switch buttonLabel {
-		case "Delete":
-			a.deleteSelectedItem()
-		case "Don't ask":
-			// TOOD: remember not to ask again
-			a.deleteSelectedItem()
+		case deleter.Verb(a.pendingStrategy):
+			a.deleteSelectedItems()
+		case confirmSession:
+			skipConfirmSession.Store(true)
+			a.deleteSelectedItems()
+		case confirmNever:
+			a.neverConfirm()
+			a.deleteSelectedItems()
+		default:
+			a.pendingDelete = nil
+		}
+	})
+
+	quitModal.SetDoneFunc(func(_ int, buttonLabel string) {
+		switch buttonLabel {
+		case quitWait:
+			a.quitWhenIdle = true
+		case quitCancel:
+			a.quitWhenIdle = true
+			a.deletions.CancelAll()
+		case quitForce:
+			a.forceQuit = true
+			a.Stop()
+			a.app.Stop()
+			return
+		default:
+			a.quitWhenIdle = false
+			a.showQuit = false
+			a.setRoot(flex, true)
+			return
 		}
+		a.refreshDeletions()
+	})
+
+	historyModal.SetDoneFunc(func(_ int, _ string) {
+		a.showHistory = false
+		a.setRoot(flex, true)
+	})
+
+	reportModal.SetDoneFunc(func(_ int, _ string) {
+		a.showReport = false
+		a.setRoot(flex, true)
 	})
 
 	themeModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
@@ -183,7 +367,7 @@ func NewApp(scanPath string) *App {
 	a.userHomeDir = home
 
 	header.SetTextAlign(cview.AlignCenter)
-	header.SetText(headerStartupStatus(&theme, a.rootPath))
+	header.SetText(a.headerDryRun() + headerStartupStatus(&theme, a.rootPath))
 	footer.SetTextAlign(cview.AlignCenter)
 	footer.SetText(footerStatusMenu(&theme))
 
@@ -212,3 +396,17 @@ func (a *App) ShouldRestart() bool {
 func (a *App) Scanner() *scanner.Scanner {
 	return a.scanner
 }
+
+// Interrupt cancels the pending deletions and quits, for when the process is
+// told to stop from outside
+func (a *App) Interrupt() {
+	a.deletions.CancelAll()
+	a.Stop()
+	a.app.Stop()
+}
+
+// ForceQuit reports whether the user chose to quit without waiting for
+// pending deletions
+func (a *App) ForceQuit() bool {
+	return a.forceQuit
+}
@@ -3,8 +3,31 @@ package tui
 import "github.com/gdamore/tcell/v3"
 
 func (a *App) handleInput(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyCtrlC {
+		if a.showQuit {
+			// Asked twice, the user really wants out
+			a.forceQuit = true
+			a.Stop()
+			a.app.Stop()
+		} else {
+			a.quit()
+		}
+		return nil
+	}
+
+	if a.showQueue {
+		return a.handleQueueInput(event)
+	}
+	if a.showReinstall {
+		return a.handleReinstallInput(event)
+	}
+	if a.showTarget {
+		// The form takes text, keep the shortcuts out of it
+		return event
+	}
+
 	// TODO: Fix the modal handling
-	if a.showDetail || a.showConfirm || a.showTheme {
+	if a.showDetail || a.showConfirm || a.showTheme || a.showHistory || a.showQuit || a.showReport {
 		// Let modals handle their own input
 		switch event.Str() {
 		case "l":
@@ -30,8 +53,7 @@ func (a *App) handleInput(event *tcell.EventKey) *tcell.EventKey {
 
 	switch event.Str() {
 	case "q", "Q":
-		a.Stop()
-		a.app.Stop()
+		a.quit()
 		return nil
 	case "r", "R":
 		if !a.isRestarting.Load() {
@@ -45,8 +67,33 @@ func (a *App) handleInput(event *tcell.EventKey) *tcell.EventKey {
 		a.showItemDetail()
 	case "d", "D":
 		a.confirmDelete()
+	case "m", "M":
+		a.confirmRelocate()
 	case "t", "T":
 		a.showThemeSelector()
+	case "l", "L":
+		a.showDeletionHistory()
+	case "u", "U":
+		a.undoLastDeletion()
+	case "w", "W":
+		a.showDeleteQueue()
+	case "P":
+		a.applyPolicy()
+	case "g", "G":
+		a.showTargetForm()
+	case "p":
+		a.toggleProtection()
+	case "n", "N":
+		if module := a.selectedModule(); module != nil {
+			a.reinstall(module.Path)
+		}
+	case " ":
+		a.toggleSelection()
+		return nil
+	case "a":
+		a.selectAll()
+	case "A":
+		a.invertSelection()
 	}
 
 	return event
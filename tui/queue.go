@@ -0,0 +1,277 @@
+package tui
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"codeberg.org/tslocum/cview"
+	"github.com/dustin/go-humanize"
+	"github.com/gdamore/tcell/v3"
+	"github.com/riadafridishibly/npmclean/deleter"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// onDeleteUpdate is called by the deletion workers whenever a job changes,
+// the table and a.items are only touched from the UI goroutine
+func (a *App) onDeleteUpdate(j deleter.Job) {
+	switch j.State {
+	case deleter.JobDone:
+		a.batchEstimated.Add(deleter.Estimate(j.Strategy, j.Module))
+		// Remove from cache after successful deletion
+		if a.store != nil {
+			a.store.Delete(j.Module.Path)
+		}
+		a.recordDeletion(j.Module, j.Strategy, nil)
+		a.trySendUIUpdate(func() { a.forgetItem(j.Module.Path) })
+	case deleter.JobFailed:
+		log.Printf("Error running %s on dir: %s: error: %v", j.Strategy.Name(), j.Module.Path, j.Err)
+		a.recordDeletion(j.Module, j.Strategy, j.Err)
+		// Whatever is left is still there, a retry from the queue removes it
+		a.trySendUIUpdate(func() { a.handleResult(j.Module) })
+	case deleter.JobCancelled:
+		log.Printf("Cancelled %s of dir: %s", j.Strategy.Name(), j.Module.Path)
+		a.recordDeletion(j.Module, j.Strategy, j.Err)
+		a.trySendUIUpdate(func() { a.handleResult(j.Module) })
+	}
+
+	a.trySendUIUpdate(a.refreshDeletions)
+	if j.State == deleter.JobFailed {
+		verb, p, err := deleter.Verb(j.Strategy), j.Module.Path, j.Err
+		a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("%s failed for %q: %v  (w: Queue)", verb, p, err)) })
+		time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+	}
+}
+
+// forgetItem drops a tree that was put back in the table when its deletion
+// failed and was then retried successfully. Runs on the UI goroutine.
+func (a *App) forgetItem(path string) {
+	i := slices.IndexFunc(a.items, func(mod *scanner.NodeModuleInfo) bool { return mod.Path == path })
+	if i < 0 {
+		return
+	}
+	a.totalClaimableSize.Add(-a.items[i].Size)
+	a.items = slices.Delete(a.items, i, i+1)
+	a.trySendUIUpdate(func() { a.buildTable() })
+}
+
+func (a *App) refreshDeletions() {
+	a.finishBatch()
+	if a.showQuit {
+		if a.quitWhenIdle && a.deletions.Pending() == 0 {
+			a.Stop()
+			a.app.Stop()
+			return
+		}
+		a.updateQuitModal()
+	}
+	if a.showQueue {
+		a.buildQueueTable()
+	}
+	a.updateFinalStatus()
+}
+
+// spaceReport is what a batch of deletions did to the free space of the
+// root's filesystem
+type spaceReport struct {
+	freed, estimated int64
+}
+
+// startBatch samples the free space when deletions are queued while the
+// queue is idle
+func (a *App) startBatch() {
+	if a.batchActive {
+		return
+	}
+	free, err := deleter.FreeSpace(a.rootPath)
+	if err != nil {
+		log.Printf("Failed to sample free space: %v", err)
+		return
+	}
+	a.batchActive = true
+	a.batchFreeAt = free
+	a.batchEstimated.Store(0)
+}
+
+// finishBatch samples the free space again once the queue drained
+func (a *App) finishBatch() {
+	if !a.batchActive || a.deletions.Pending() > 0 {
+		return
+	}
+	a.batchActive = false
+	free, err := deleter.FreeSpace(a.rootPath)
+	if err != nil {
+		log.Printf("Failed to sample free space: %v", err)
+		return
+	}
+
+	// Other programs writing to the disk meanwhile can make this negative
+	report := &spaceReport{freed: free - a.batchFreeAt, estimated: a.batchEstimated.Load()}
+	a.lastBatch = report
+	log.Printf("Deletions freed %s, estimated %s", deleter.SignedBytes(report.freed), humanize.Bytes(uint64(report.estimated)))
+	a.trySendUIUpdate(func() {
+		a.footer.SetText(fmt.Sprintf("Actually freed %s, estimated %s", deleter.SignedBytes(report.freed), humanize.Bytes(uint64(report.estimated))))
+	})
+	time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+}
+
+// Quit modal buttons
+const (
+	quitWait   = "Wait"
+	quitCancel = "Cancel remaining"
+	quitForce  = "Force quit"
+	quitBack   = "Back"
+)
+
+// quit stops the app, asking first when deletions are still queued or running
+// as quitting in the middle of one leaves a half deleted tree behind
+func (a *App) quit() {
+	if a.deletions.Pending() == 0 {
+		a.Stop()
+		a.app.Stop()
+		return
+	}
+	a.showQuit = true
+	a.updateQuitModal()
+	a.setRoot(a.quitModal, false)
+}
+
+func (a *App) updateQuitModal() {
+	running, queued, freed := a.deletionProgress()
+	text := fmt.Sprintf("Deletions are still in progress\n\n%d running, %d queued, %s freed\n\nQuitting now can leave a half deleted tree behind",
+		running, queued, humanize.Bytes(uint64(freed)))
+	if a.quitWhenIdle {
+		text = fmt.Sprintf("Waiting for deletions to finish before quitting\n\n%d running, %d queued, %s freed\n\nForce quit to stop waiting",
+			running, queued, humanize.Bytes(uint64(freed)))
+	}
+	a.quitModal.SetText(text)
+}
+
+// deletionProgress sums up the jobs that are still queued or running
+func (a *App) deletionProgress() (running, queued int, freed int64) {
+	for _, j := range a.deletions.Jobs() {
+		switch j.State {
+		case deleter.JobRunning:
+			running++
+			freed += j.Bytes
+		case deleter.JobQueued:
+			queued++
+		}
+	}
+	return running, queued, freed
+}
+
+func (a *App) showDeleteQueue() {
+	a.showQueue = true
+	a.buildQueueTable()
+	a.setRoot(a.queuePanel, true)
+}
+
+func (a *App) hideDeleteQueue() {
+	a.showQueue = false
+	a.setRoot(a.layout, true)
+}
+
+func (a *App) buildQueueTable() {
+	theme := a.currentTheme
+	table := a.queueTable
+	row, _ := table.GetSelection()
+	table.Clear()
+
+	for i, j := range a.deletions.Jobs() {
+		stateCell := cview.NewTableCell(" " + string(j.State))
+		stateCell.SetReference(j.ID)
+		switch j.State {
+		case deleter.JobDone:
+			stateCell.SetTextColor(theme.green)
+		case deleter.JobFailed:
+			stateCell.SetTextColor(theme.red)
+		case deleter.JobRunning:
+			stateCell.SetTextColor(theme.orange)
+		default:
+			stateCell.SetTextColor(theme.fg)
+		}
+		table.SetCell(i, 0, stateCell)
+
+		progress := fmt.Sprintf(" %s / %s ", humanize.Bytes(uint64(j.Bytes)), humanize.Bytes(uint64(j.Module.Size)))
+		progressCell := cview.NewTableCell(progress)
+		progressCell.SetTextColor(theme.yellow)
+		progressCell.SetAlign(cview.AlignRight)
+		table.SetCell(i, 1, progressCell)
+
+		filesCell := cview.NewTableCell(fmt.Sprintf("%s files ", humanize.Comma(j.Files)))
+		filesCell.SetTextColor(theme.fg)
+		filesCell.SetAlign(cview.AlignRight)
+		table.SetCell(i, 2, filesCell)
+
+		text := a.replaceHomeWithTilde(j.Module.Path)
+		if j.Strategy != a.deleter {
+			text = fmt.Sprintf("%s (%s)", text, strings.ToLower(deleter.Verb(j.Strategy)))
+		}
+		if j.State == deleter.JobFailed && j.Err != nil {
+			text = fmt.Sprintf("%s: %v", text, j.Err)
+		}
+		pathCell := cview.NewTableCell(text)
+		pathCell.SetTextColor(theme.fg)
+		pathCell.SetExpansion(1)
+		table.SetCell(i, 3, pathCell)
+	}
+
+	if table.GetRowCount() == 0 {
+		table.SetCell(0, 0, cview.NewTableCell(" Nothing queued"))
+	}
+	if row >= table.GetRowCount() {
+		row = table.GetRowCount() - 1
+	}
+	table.Select(max(row, 0), 0)
+}
+
+// selectedJob returns the id of the job under the cursor in the queue panel
+func (a *App) selectedJob() (int, bool) {
+	row, _ := a.queueTable.GetSelection()
+	cell := a.queueTable.GetCell(row, 0)
+	if cell == nil {
+		return 0, false
+	}
+	id, ok := cell.GetReference().(int)
+	return id, ok
+}
+
+func (a *App) handleQueueInput(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		a.hideDeleteQueue()
+		return nil
+	}
+
+	switch event.Str() {
+	case "w", "W", "q", "Q":
+		a.hideDeleteQueue()
+	case "c":
+		if id, ok := a.selectedJob(); ok {
+			a.deletions.Cancel(id)
+		}
+	case "C":
+		a.deletions.CancelAll()
+	case "r", "R":
+		if id, ok := a.selectedJob(); ok {
+			a.deletions.Retry(id)
+		}
+	case "x", "X":
+		a.deletions.ClearFinished()
+		a.buildQueueTable()
+	case "n", "N":
+		if id, ok := a.selectedJob(); ok {
+			for _, j := range a.deletions.Jobs() {
+				if j.ID == id && j.State == deleter.JobDone && j.Strategy.Name() != deleter.StrategyRelocate {
+					a.showQueue = false
+					a.reinstall(j.Module.Path)
+				}
+			}
+		}
+	default:
+		return event
+	}
+	return nil
+}
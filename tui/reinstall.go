@@ -0,0 +1,129 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"codeberg.org/tslocum/cview"
+	"github.com/gdamore/tcell/v3"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/project"
+	"github.com/riadafridishibly/npmclean/scanner"
+)
+
+// drawWriter redraws the screen after every write, so command output shows
+// up as it arrives
+type drawWriter struct {
+	a *App
+	w io.Writer
+}
+
+func (d drawWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.a.trySendUIUpdate(func() {})
+	return n, err
+}
+
+// reinstall runs the project's install command for the node_modules at path
+// and streams its output into the reinstall panel. One install runs at a
+// time, asking for another one while it runs just shows the panel.
+func (a *App) reinstall(path string) {
+	if a.reinstallCancel != nil {
+		a.showReinstallPanel()
+		return
+	}
+
+	info := project.Detect(path)
+	command := project.CommandFor(info.PackageManager, a.config.InstallCommand)
+
+	a.reinstallView.Clear()
+	a.reinstallView.SetTitle(fmt.Sprintf(" Reinstall %s ", info.Name))
+	a.showReinstallPanel()
+	if a.config.DryRun {
+		fmt.Fprintf(a.reinstallView, "Dry run, would run in %s:\n$ %s\n", a.replaceHomeWithTilde(info.Dir), strings.Join(command, " "))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.reinstallCancel = cancel
+	out := drawWriter{a: a, w: cview.ANSIWriter(a.reinstallView)}
+	go func() {
+		err := project.Install(ctx, info, command, out)
+		if err != nil {
+			log.Printf("Reinstall of %s failed: %v", info.Dir, err)
+		} else {
+			a.trackReinstalled(filepath.Join(info.Dir, "node_modules"))
+		}
+
+		a.trySendUIUpdate(func() {
+			a.reinstallCancel = nil
+			if err != nil {
+				fmt.Fprintf(a.reinstallView, "\n[%s]Failed: %v[-]\n", a.currentTheme.red.String(), err)
+			} else {
+				fmt.Fprintf(a.reinstallView, "\n[%s]Done[-]\n", a.currentTheme.green.String())
+			}
+		})
+	}()
+}
+
+// trackReinstalled measures a freshly installed tree and puts it back in the
+// table and the cache
+func (a *App) trackReinstalled(path string) {
+	res, err := scanner.GetDirectorySize(path)
+	if err != nil {
+		log.Printf("Failed to measure %s: %v", path, err)
+		return
+	}
+	info := &scanner.NodeModuleInfo{Path: path, Size: res.Size, ScannedAt: time.Now()}
+	if info.LastModifiedAt, err = scanner.GetLastModifiedAt(path); err != nil {
+		info.LastModifiedAt = info.ScannedAt
+	}
+	a.store.InsertOrUpdate(&cache.CacheEntry{
+		Path:           info.Path,
+		Size:           info.Size,
+		LastModifiedAt: info.LastModifiedAt,
+		ScannedAt:      info.ScannedAt,
+	})
+
+	a.trySendUIUpdate(func() {
+		if strings.HasPrefix(info.Path, a.rootPath) {
+			a.handleResult(info)
+			a.updateFinalStatus()
+		}
+	})
+}
+
+func (a *App) showReinstallPanel() {
+	a.showReinstall = true
+	a.setRoot(a.reinstallPanel, true)
+}
+
+func (a *App) hideReinstallPanel() {
+	a.showReinstall = false
+	a.setRoot(a.layout, true)
+}
+
+func (a *App) handleReinstallInput(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		a.hideReinstallPanel()
+		return nil
+	}
+
+	switch event.Str() {
+	case "n", "N", "q", "Q":
+		// The install keeps running, n brings the panel back
+		a.hideReinstallPanel()
+	case "c", "C":
+		if a.reinstallCancel != nil {
+			a.reinstallCancel()
+		}
+	default:
+		return event
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"codeberg.org/tslocum/cview"
 	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/deleter"
 	"github.com/riadafridishibly/npmclean/scanner"
 )
 
@@ -37,8 +38,42 @@ func headerStatusError(theme *Theme, err error) string {
 	return fmt.Sprintf("[%s] Error: %v", theme.darkGray.String(), err)
 }
 
+// headerDryRun marks the header so dry run mode is never mistaken for the real thing
+func (a *App) headerDryRun() string {
+	if !a.config.DryRun {
+		return ""
+	}
+	return fmt.Sprintf("[::b][%s]DRY RUN[::-][-] |", a.currentTheme.red.String())
+}
+
+// headerSpace shows the free space of the root's filesystem, and what the
+// last batch of deletions actually freed next to the estimate
+func (a *App) headerSpace() string {
+	theme := a.currentTheme
+	free, err := deleter.FreeSpace(a.rootPath)
+	if err != nil {
+		return ""
+	}
+	s := fmt.Sprintf("| Free: [::b][%s]%s[::-][-] ", theme.darkGray.String(), humanize.Bytes(uint64(free)))
+	if b := a.lastBatch; b != nil {
+		s += fmt.Sprintf("| Freed: [%s]%s[-] of [%s]%s[-] est. ",
+			theme.darkGray.String(), deleter.SignedBytes(b.freed), theme.darkGray.String(), humanize.Bytes(uint64(b.estimated)))
+	}
+	return s
+}
+
 func footerStatusMenu(theme *Theme) string {
-	return fmt.Sprintf("[%s] r: Rescan  ↑/↓: Navigate  i: Details  d: Delete  t: Theme  q: Quit", theme.fg.String())
+	return fmt.Sprintf("[%s] r: Rescan  ↑/↓: Navigate  i: Details  space: Select  d: Delete  m: Move  p: Protect  P: Policy  g: Goal  n: Reinstall  w: Queue  u: Undo  l: Log  t: Theme  q: Quit", theme.fg.String())
+}
+
+func footerStatusSelection(theme *Theme, count int, size int64) string {
+	return fmt.Sprintf("[%s] Selected: [%s]%d[-] items, [::b][%s]%s[::-][-]  space: Toggle  a: All  A: Invert  d: Delete selected",
+		theme.fg.String(), theme.green.String(), count, theme.green.String(), humanize.Bytes(uint64(size)))
+}
+
+func footerStatusDeleting(theme *Theme, running, queued int, freed int64) string {
+	return fmt.Sprintf("[%s] Deleting: [%s]%d[-] running, [%s]%d[-] queued, freed [::b][%s]%s[::-][-]  w: Queue",
+		theme.fg.String(), theme.orange.String(), running, theme.darkGray.String(), queued, theme.green.String(), humanize.Bytes(uint64(freed)))
 }
 
 func footerStatusScanning(theme *Theme, path string) string {
@@ -53,9 +88,17 @@ func (a *App) updateFinalStatus() {
 	fileCount := a.scanner.FileCount()
 
 	a.header.SetTextAlign(cview.AlignCenter)
-	a.header.SetText(headerStatus(&a.currentTheme, int64(len(a.items)), fileCount, a.totalClaimableSize.Load(), a.scanner.ElapsedTime(), a.scanner.IsRunning()))
+	a.header.SetText(a.headerDryRun() + headerStatus(&a.currentTheme, int64(len(a.items)), fileCount, a.totalClaimableSize.Load(), a.scanner.ElapsedTime(), a.scanner.IsRunning()) + a.headerSpace())
 
 	a.footer.SetTextAlign(cview.AlignCenter)
+	if running, queued, freed := a.deletionProgress(); running+queued > 0 {
+		a.footer.SetText(footerStatusDeleting(&a.currentTheme, running, queued, freed))
+		return
+	}
+	if count, size := a.selectionSize(); count > 0 {
+		a.footer.SetText(footerStatusSelection(&a.currentTheme, count, size))
+		return
+	}
 	a.footer.SetText(footerStatusMenu(&a.currentTheme))
 }
 
@@ -73,7 +116,7 @@ func (a *App) updateProgressStatus(progress *scanner.ScanResult) {
 	theme := a.currentTheme
 
 	a.header.SetTextAlign(cview.AlignCenter)
-	a.header.SetText(headerStatus(&theme, int64(len(a.items)), progress.FileCount, a.totalClaimableSize.Load(), a.scanner.ElapsedTime(), progress.Done))
+	a.header.SetText(a.headerDryRun() + headerStatus(&theme, int64(len(a.items)), progress.FileCount, a.totalClaimableSize.Load(), a.scanner.ElapsedTime(), progress.Done) + a.headerSpace())
 
 	a.lastUpdate = time.Now()
 
@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"codeberg.org/tslocum/cview"
+	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/deleter"
+)
+
+// showTargetForm asks how much space to make, the trees to delete are then
+// picked to meet it
+func (a *App) showTargetForm() {
+	theme := a.currentTheme
+	form := cview.NewForm()
+	form.SetBorder(true)
+	form.SetBackgroundColor(theme.modalBg)
+	form.SetLabelColor(theme.modalFg)
+	form.SetFieldBackgroundColor(theme.darkGray)
+	form.SetFieldTextColor(theme.fg)
+	form.SetButtonBackgroundColor(theme.buttonBg)
+	form.SetButtonTextColor(theme.buttonFg)
+	form.SetButtonsAlign(cview.AlignCenter)
+
+	title := " Make space "
+	if free, err := deleter.FreeSpace(a.rootPath); err == nil {
+		title = fmt.Sprintf(" Make space, %s free now ", humanize.Bytes(uint64(free)))
+	}
+	form.SetTitle(title)
+
+	goal := cview.NewInputField()
+	goal.SetLabel("Goal ")
+	goal.SetFieldWidth(24)
+	goal.SetText(a.lastGoal)
+	goal.SetPlaceholder("20GB free or +5GB")
+	form.AddFormItem(goal)
+	form.AddDropDownSimple("Pick first ", 0, nil, deleter.Orders...)
+
+	submit := func() {
+		_, option := form.GetFormItem(1).(*cview.DropDown).GetCurrentOption()
+		a.hideTargetForm()
+		a.planTarget(goal.GetText(), option.GetText())
+	}
+	form.AddButton("Plan", submit)
+	form.AddButton("Cancel", a.hideTargetForm)
+	form.SetCancelFunc(a.hideTargetForm)
+
+	a.showTarget = true
+	a.setRoot(centered(form, 56, 9), true)
+}
+
+func (a *App) hideTargetForm() {
+	a.showTarget = false
+	a.setRoot(a.layout, true)
+}
+
+// planTarget picks the trees to delete to meet the goal in text and asks
+// for confirmation like any other deletion
+func (a *App) planTarget(text, order string) {
+	goal, err := deleter.ParseGoal(text)
+	if err != nil {
+		a.footer.SetText(err.Error())
+		time.AfterFunc(3*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+		return
+	}
+	a.lastGoal = text
+
+	// The results keep coming in while the plan is built
+	modules := slices.Clone(a.items)
+	a.footer.SetText("Planning...")
+	go func() {
+		need, err := goal.Need(a.rootPath)
+		if err == nil && need <= 0 {
+			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Already met: %s", goal)) })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+			return
+		}
+		var plan *deleter.Plan
+		if err == nil {
+			plan, err = deleter.NewTargetPlan(a.deleter, a.deletions.Guard(), modules, need, order)
+		}
+		if err != nil {
+			log.Printf("Failed to plan for %s: %v", goal, err)
+			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Failed to plan: %v", err)) })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+			return
+		}
+		a.trySendUIUpdate(func() {
+			a.updateFinalStatus()
+			a.confirmPlan(plan, a.deleter)
+		})
+	}()
+}
+
+// centered places p in the middle of the screen
+func centered(p cview.Primitive, width, height int) cview.Primitive {
+	rows := cview.NewFlex()
+	rows.SetDirection(cview.FlexRow)
+	rows.AddItem(nil, 0, 1, false)
+	rows.AddItem(p, height, 0, true)
+	rows.AddItem(nil, 0, 1, false)
+
+	cols := cview.NewFlex()
+	cols.AddItem(nil, 0, 1, false)
+	cols.AddItem(rows, width, 0, true)
+	cols.AddItem(nil, 0, 1, false)
+	return cols
+}
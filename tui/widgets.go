@@ -4,14 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"codeberg.org/tslocum/cview"
 	"github.com/dustin/go-humanize"
+	"github.com/riadafridishibly/npmclean/cache"
+	"github.com/riadafridishibly/npmclean/config"
+	"github.com/riadafridishibly/npmclean/deleter"
 	"github.com/riadafridishibly/npmclean/scanner"
 )
 
@@ -20,7 +24,7 @@ func (a *App) IsScanning() bool {
 }
 
 func (a *App) startScanning() {
-	a.scanner = scanner.NewScanner(a.rootPath)
+	a.scanner = scanner.NewScanner(a.rootPath, a.store)
 
 	// Load cached results first
 	if cachedResults, err := a.scanner.LoadCachedResults(); err == nil {
@@ -48,9 +52,23 @@ func (a *App) buildTable() *cview.Table {
 	table.Clear()
 	items := a.items[:]
 	sort.Slice(items, func(i, j int) bool { return items[i].Size > items[j].Size })
+	now := time.Now()
 	for row, item := range items {
+		selected := a.selected[item.Path]
+		protected := a.deletions.Guard().ProtectedBy(item.Path) != ""
+		matched := a.policy.Match(item, now) != ""
+		marker := "  "
+		switch {
+		case protected:
+			marker = "◆ "
+		case selected:
+			marker = "● "
+		case matched:
+			marker = "▸ "
+		}
+
 		// Access
-		accessCell := cview.NewTableCell(" " + humanize.Time(item.LastModifiedAt))
+		accessCell := cview.NewTableCell(" " + marker + humanize.Time(item.LastModifiedAt))
 		accessCell.SetTextColor(theme.fg)
 		accessCell.SetAlign(cview.AlignLeft)
 
@@ -73,6 +91,17 @@ func (a *App) buildTable() *cview.Table {
 		// Path
 		pathCell := cview.NewTableCell(a.replaceHomeWithTilde(item.Path))
 		pathCell.SetTextColor(theme.fg)
+		switch {
+		case protected:
+			accessCell.SetTextColor(theme.orange)
+			pathCell.SetTextColor(theme.orange)
+		case selected:
+			accessCell.SetTextColor(theme.green)
+			pathCell.SetTextColor(theme.green)
+		case matched:
+			accessCell.SetTextColor(theme.red)
+			pathCell.SetTextColor(theme.red)
+		}
 		pathCell.SetAlign(cview.AlignLeft)
 		pathCell.SetExpansion(1)
 		table.SetCell(row, 2, pathCell)
@@ -147,80 +176,484 @@ func (a *App) showItemDetail() {
 	fmt.Fprintf(&detail, "Size: %s\n", humanize.Bytes(uint64(item.Size)))
 	fmt.Fprintf(&detail, "Last Modified: %s\n", item.LastModifiedAt.Format("2006-01-02 15:04:05 MST"))
 	fmt.Fprintf(&detail, "Scanned At: %s\n", item.ScannedAt.Format(time.Kitchen))
+	if rule := a.policy.Match(item, time.Now()); rule != "" {
+		fmt.Fprintf(&detail, "Cleanup rule: %s\n", rule)
+	}
 
 	a.detailModal.SetText(detail.String())
 	a.showDetail = true
 	a.setRoot(a.detailModal, false)
 }
 
-func (a *App) confirmDelete() {
+func (a *App) selectedModule() *scanner.NodeModuleInfo {
 	if a.table == nil {
-		return
+		return nil
 	}
 	row, _ := a.table.GetSelection()
 	cell := a.table.GetCell(row, 0)
 	if cell == nil {
-		return
+		return nil
 	}
 	module, ok := cell.GetReference().(*scanner.NodeModuleInfo)
 	if !ok {
-		// TODO: Send error
+		return nil
+	}
+	return module
+}
+
+// deletionTargets are the marked items, or the row under the cursor if
+// nothing is marked
+func (a *App) deletionTargets() []*scanner.NodeModuleInfo {
+	if len(a.selected) == 0 {
+		if module := a.selectedModule(); module != nil {
+			return []*scanner.NodeModuleInfo{module}
+		}
+		return nil
+	}
+	var modules []*scanner.NodeModuleInfo
+	for _, item := range a.items {
+		if a.selected[item.Path] {
+			modules = append(modules, item)
+		}
+	}
+	return modules
+}
+
+func (a *App) toggleSelection() {
+	module := a.selectedModule()
+	if module == nil {
+		return
+	}
+	if a.selected[module.Path] {
+		delete(a.selected, module.Path)
+	} else {
+		a.selected[module.Path] = true
+	}
+
+	// Move on to the next row so space can be held down to mark a range
+	row, col := a.table.GetSelection()
+	if row+1 < a.table.GetRowCount() {
+		a.table.Select(row+1, col)
+	}
+	a.buildTable()
+	a.updateFinalStatus()
+}
+
+// selectAll marks every item except protected ones, which would only be
+// skipped
+func (a *App) selectAll() {
+	for _, item := range a.items {
+		if a.deletions.Guard().ProtectedBy(item.Path) == "" {
+			a.selected[item.Path] = true
+		}
+	}
+	a.buildTable()
+	a.updateFinalStatus()
+}
+
+func (a *App) invertSelection() {
+	for _, item := range a.items {
+		if a.selected[item.Path] {
+			delete(a.selected, item.Path)
+		} else if a.deletions.Guard().ProtectedBy(item.Path) == "" {
+			a.selected[item.Path] = true
+		}
+	}
+	a.buildTable()
+	a.updateFinalStatus()
+}
+
+func (a *App) selectionSize() (count int, size int64) {
+	for _, item := range a.items {
+		if a.selected[item.Path] {
+			count++
+			size += item.Size
+		}
+	}
+	return count, size
+}
+
+func (a *App) confirmDelete() {
+	a.confirmAction(a.deleter, a.deletionTargets())
+}
+
+// confirmRelocate moves the targets to cold storage after confirmation
+func (a *App) confirmRelocate() {
+	if a.relocator == nil {
+		a.footer.SetText("Set cold_storage_dir in the config file to move trees")
+		time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
 		return
 	}
-	baseName := module.Path
-	text := fmt.Sprintf("Delete '%s'?\n\nSize: %s", baseName, humanize.Bytes(uint64(module.Size)))
-	a.confirmModal.SetText(text)
+	a.confirmAction(a.relocator, a.deletionTargets())
+}
+
+// applyPolicy deletes everything the cleanup rules match, after the same
+// checks and confirmation as any other deletion
+func (a *App) applyPolicy() {
+	var modules []*scanner.NodeModuleInfo
+	for _, m := range a.policy.Select(a.items, time.Now()) {
+		modules = append(modules, m.Module)
+	}
+	if len(modules) == 0 {
+		if a.policy.Empty() {
+			a.footer.SetText("No cleanup rules in the config file")
+		} else {
+			a.footer.SetText("Nothing matches the cleanup rules")
+		}
+		time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+		return
+	}
+	a.confirmAction(a.deleter, modules)
+}
+
+// confirmAction runs strategy on modules once they passed the safety checks
+// and were confirmed
+func (a *App) confirmAction(strategy deleter.Strategy, modules []*scanner.NodeModuleInfo) {
+	if len(modules) == 0 {
+		return
+	}
+
+	// The safety checks run git and look through /proc, keep the UI responsive
+	a.footer.SetText("Checking...")
+	go func() {
+		plan := deleter.NewPlan(strategy, a.deletions.Guard(), modules)
+		a.trySendUIUpdate(func() {
+			a.updateFinalStatus()
+			a.confirmPlan(plan, strategy)
+		})
+	}()
+}
+
+// Confirm modal buttons besides the strategy verb and Cancel
+const (
+	confirmSession = "Not this session"
+	confirmNever   = "Don't ask again"
+)
+
+// skipConfirmSession outlives the App, which is recreated on every rescan
+var skipConfirmSession atomic.Bool
+
+// shouldConfirm applies the confirmation policy to a deletion freeing bytes,
+// the total of the whole batch
+func (a *App) shouldConfirm(bytes int64) bool {
+	if skipConfirmSession.Load() {
+		return false
+	}
+	switch a.config.Confirm {
+	case config.ConfirmNever:
+		return false
+	case config.ConfirmLarge:
+		above := int64(a.config.ConfirmAbove)
+		if above <= 0 {
+			above = config.DefaultConfirmAbove
+		}
+		return bytes >= above
+	default:
+		return true
+	}
+}
+
+// neverConfirm stops asking, for good
+func (a *App) neverConfirm() {
+	a.updateConfig(func(c *config.Config) { c.Confirm = config.ConfirmNever })
+}
+
+// updateConfig applies fn to the running config and saves the same change to
+// the config file in the background
+func (a *App) updateConfig(fn func(*config.Config)) {
+	fn(a.config)
+	prev, done := a.configSaved, make(chan struct{})
+	a.configSaved = done
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		if err := config.Update(fn); err != nil {
+			log.Printf("Failed to save config: %v", err)
+			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Failed to save config: %v", err)) })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+		}
+	}()
+}
+
+// toggleProtection protects the project of the row under the cursor, or
+// lifts its protection
+func (a *App) toggleProtection() {
+	module := a.selectedModule()
+	if module == nil {
+		return
+	}
+	guard := a.deletions.Guard()
+	dir := filepath.Dir(module.Path)
+
+	by := guard.ProtectedBy(module.Path)
+	if by != "" && by != dir {
+		a.footer.SetText(fmt.Sprintf("Protected by %s, change protected_paths in the config file to lift it", a.replaceHomeWithTilde(by)))
+		time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+		return
+	}
+
+	protect := by == ""
+	if protect {
+		guard.Protect(dir)
+	} else {
+		guard.Unprotect(dir)
+	}
+	a.updateConfig(func(c *config.Config) {
+		c.ProtectedPaths = slices.DeleteFunc(c.ProtectedPaths, func(p string) bool {
+			abs, err := config.AbsPath(p)
+			return err == nil && abs == dir
+		})
+		if protect {
+			c.ProtectedPaths = append(c.ProtectedPaths, dir)
+		}
+	})
+	a.buildTable()
+}
+
+// confirmPlan asks before running strategy on the allowed items of plan, as
+// the confirmation policy says, and lists why the others are skipped
+func (a *App) confirmPlan(plan *deleter.Plan, strategy deleter.Strategy) {
+	allowed := plan.Allowed()
+	if a.config.DryRun || len(allowed) == 0 {
+		a.showPlanReport(plan)
+		return
+	}
+	verb := deleter.Verb(strategy)
+	if !a.shouldConfirm(plan.Bytes()) {
+		a.pendingDelete = allowed
+		a.pendingStrategy = strategy
+		a.deleteSelectedItems()
+		if blocked := plan.Blocked(); len(blocked) > 0 {
+			for _, item := range blocked {
+				log.Printf("Skipped %s: %s", item.Module.Path, item.Blocked)
+			}
+			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Skipped %d blocked items, see the log", len(blocked))) })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+		}
+		return
+	}
+
+	var text strings.Builder
+	if len(allowed) == 1 {
+		fmt.Fprintf(&text, "%s '%s'?\n\nSize: %s", verb, allowed[0].Path, humanize.Bytes(uint64(allowed[0].Size)))
+	} else {
+		fmt.Fprintf(&text, "%s %d items?\n\nSize: %s", verb, len(allowed), humanize.Bytes(uint64(plan.Bytes())))
+	}
+	if frees := plan.Frees(); frees != plan.Bytes() {
+		fmt.Fprintf(&text, "\nFrees: %s", humanize.Bytes(uint64(frees)))
+	}
+	if plan.Goal > 0 {
+		fmt.Fprintf(&text, "\nGoal: %s", humanize.Bytes(uint64(plan.Goal)))
+		if short := plan.Short(); short > 0 {
+			fmt.Fprintf(&text, ", short by %s", humanize.Bytes(uint64(short)))
+		}
+	}
+	if relocator, ok := strategy.(*deleter.Relocator); ok {
+		fmt.Fprintf(&text, "\nTo: %s", a.replaceHomeWithTilde(relocator.Target(allowed[0].Path)))
+		if len(allowed) > 1 {
+			text.WriteString(" ...")
+		}
+	}
+	for i, item := range plan.Blocked() {
+		if i == 0 {
+			text.WriteString("\n\nSkipping:")
+		}
+		if i == planReportLimit {
+			fmt.Fprintf(&text, "\n... and %d more", len(plan.Blocked())-planReportLimit)
+			break
+		}
+		fmt.Fprintf(&text, "\n%s: %s", a.replaceHomeWithTilde(item.Module.Path), item.Blocked)
+	}
+
+	a.pendingDelete = allowed
+	a.pendingStrategy = strategy
+	a.confirmModal.ClearButtons()
+	a.confirmModal.AddButtons([]string{verb, "Cancel", confirmSession, confirmNever})
+	a.confirmModal.SetText(text.String())
 	a.showConfirm = true
 	a.setRoot(a.confirmModal, false)
 }
 
-// FIXME: When deleting items we should also consider deleting from cache!
-// Though it'll not be a big of a problem because before using cache, currently
-// we perform an stat call and check if path exists. But the better way is
-// after deleting from system, we should delete from cache as well. Also we
-// need to wait for the delete operation to be done and prevent user from
-// closing the applicaiton.
-func (a *App) deleteSelectedItem() {
-	row, _ := a.table.GetSelection()
-	cell := a.table.GetCell(row, 0)
-	if cell == nil {
-		return
+const planReportLimit = 10
+
+// showPlanReport shows what a deletion would do instead of doing it, in dry
+// run mode or when every item is blocked
+func (a *App) showPlanReport(plan *deleter.Plan) {
+	var text strings.Builder
+	if a.config.DryRun {
+		text.WriteString("Dry run, nothing was touched\n\n")
+	} else {
+		text.WriteString("Every item is blocked\n\n")
 	}
+	plan.WriteReport(&text, planReportLimit)
 
-	module, ok := cell.GetReference().(*scanner.NodeModuleInfo)
-	if !ok {
-		// TODO: Send error
+	a.reportModal.SetText(text.String())
+	a.showReport = true
+	a.setRoot(a.reportModal, false)
+}
+
+func (a *App) deleteSelectedItems() {
+	modules, strategy := a.pendingDelete, a.pendingStrategy
+	a.pendingDelete, a.pendingStrategy = nil, nil
+	if len(modules) == 0 {
 		return
 	}
 
+	remove := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		remove[module.Path] = true
+		delete(a.selected, module.Path)
+		a.totalClaimableSize.Add(-module.Size)
+	}
 	// TODO: probably acquire lock
-	a.items = slices.DeleteFunc(a.items, func(mod *scanner.NodeModuleInfo) bool { return mod.Path == module.Path })
+	a.items = slices.DeleteFunc(a.items, func(mod *scanner.NodeModuleInfo) bool { return remove[mod.Path] })
 
-	a.totalClaimableSize.Add(-module.Size)
 	a.trySendUIUpdate(func() {
 		a.buildTable()
 		a.updateFinalStatus()
 	})
 
-	p := module.Path
-	// TODO: Delete async and update status
+	a.startBatch()
+	for _, module := range modules {
+		a.deletions.EnqueueWith(strategy, module)
+	}
+}
+
+func (a *App) recordDeletion(module *scanner.NodeModuleInfo, strategy deleter.Strategy, err error) {
+	if a.store == nil {
+		return
+	}
+	if err := deleter.Record(a.store, module, strategy, err); err != nil {
+		log.Printf("Failed to record deletion: %q: %v", module.Path, err)
+	}
+}
+
+func (a *App) showDeletionHistory() {
+	if a.historyModal == nil {
+		return
+	}
+
+	var text strings.Builder
+	if a.scanner == nil || a.scanner.Cache() == nil {
+		text.WriteString("Deletion history is not available without cache")
+	} else if records, err := a.scanner.Cache().Deletions(time.Time{}); err != nil {
+		fmt.Fprintf(&text, "Failed to load deletion history: %v", err)
+	} else {
+		writeDeletionHistory(&text, records)
+	}
+
+	a.historyModal.SetText(text.String())
+	a.showHistory = true
+	a.setRoot(a.historyModal, false)
+}
+
+const historyRecentLimit = 10
+
+func writeDeletionHistory(w *strings.Builder, records []*cache.DeletionRecord) {
+	if len(records) == 0 {
+		w.WriteString("Nothing deleted yet")
+		return
+	}
+
+	var totalBytes int64
+	w.WriteString("Reclaimed per month\n\n")
+	for _, t := range cache.SummarizeDeletions(records) {
+		fmt.Fprintf(w, "%s: %s (%d items)\n", t.Month, humanize.Bytes(uint64(t.Bytes)), t.Count)
+		totalBytes += t.Bytes
+	}
+	fmt.Fprintf(w, "Total: %s\n\nRecent deletions\n\n", humanize.Bytes(uint64(totalBytes)))
+
+	for i, rec := range records {
+		if i == historyRecentLimit {
+			break
+		}
+		status := humanize.Bytes(uint64(rec.Size))
+		if rec.Outcome != cache.OutcomeDeleted {
+			status = rec.Outcome
+		}
+		fmt.Fprintf(w, "%s  %s  %s\n", humanize.Time(rec.DeletedAt), status, rec.Path)
+	}
+}
+
+// undoLastDeletion restores the most recently quarantined or archived tree
+func (a *App) undoLastDeletion() {
 	go func() {
-		a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Deleting: %q", p)) })
-		err := os.RemoveAll(p)
-		if err != nil {
-			log.Printf("Error deleting dir: %s: error: %v", p, err)
-		} else {
-			// Remove from cache after successful deletion
-			if a.scanner != nil && a.scanner.Cache() != nil {
-				a.scanner.Cache().Delete(p)
-			}
-			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Deleted: %q", p)) })
+		var path string
+		var size int64
+		var deletedAt time.Time
+		var restore func() error
+
+		if items, err := a.store.Quarantined(); err == nil && len(items) > 0 {
+			item := items[0]
+			path, size, deletedAt = item.OriginalPath, item.Size, item.QuarantinedAt
+			restore = func() error { return deleter.Restore(a.store, item) }
+		}
+		if items, err := a.store.Archives(); err == nil && len(items) > 0 && items[0].ArchivedAt.After(deletedAt) {
+			item := items[0]
+			path, size, deletedAt = item.OriginalPath, item.Size, item.ArchivedAt
+			restore = func() error { return deleter.RestoreArchive(context.Background(), a.store, item) }
+		}
+		if restore == nil {
+			a.trySendUIUpdate(func() { a.footer.SetText("Nothing to undo") })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+			return
+		}
+
+		if a.config.DryRun {
+			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Dry run, would restore: %q", path)) })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+			return
+		}
+		a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Restoring: %q", path)) })
+		if err := restore(); err != nil {
+			log.Printf("Error restoring %s: %v", path, err)
+			a.trySendUIUpdate(func() { a.footer.SetText(fmt.Sprintf("Restore failed: %v", err)) })
+			time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
+			return
 		}
 
+		var err error
+		info := &scanner.NodeModuleInfo{Path: path, Size: size, ScannedAt: time.Now()}
+		if info.LastModifiedAt, err = scanner.GetLastModifiedAt(info.Path); err != nil {
+			info.LastModifiedAt = deletedAt
+		}
+		a.store.InsertOrUpdate(&cache.CacheEntry{
+			Path:           info.Path,
+			Size:           info.Size,
+			LastModifiedAt: info.LastModifiedAt,
+			ScannedAt:      info.ScannedAt,
+		})
+
+		a.trySendUIUpdate(func() {
+			if strings.HasPrefix(info.Path, a.rootPath) {
+				a.handleResult(info)
+			}
+			a.footer.SetText(fmt.Sprintf("Restored: %q", info.Path))
+		})
 		time.AfterFunc(2*time.Second, func() { a.trySendUIUpdate(a.updateFinalStatus) })
 	}()
 }
 
+// purgeExpiredQuarantine permanently deletes trees quarantined longer than the TTL
+func (a *App) purgeExpiredQuarantine() {
+	if a.config.DryRun {
+		return
+	}
+	ttl := deleter.DefaultQuarantineTTL
+	if a.config.QuarantineTTL > 0 {
+		ttl = time.Duration(a.config.QuarantineTTL)
+	}
+	purged, err := deleter.PurgeExpired(a.store, ttl)
+	if err != nil {
+		log.Printf("Failed to purge quarantine: %v", err)
+	}
+	if len(purged) > 0 {
+		log.Printf("Purged %d expired quarantined items", len(purged))
+	}
+}
+
 func (a *App) Stop() {
 	if a.scanner != nil {
 		a.scanner.Stop()
@@ -234,5 +667,6 @@ func (a *App) Run() error {
 		}
 	}()
 	go a.startScanning()
+	go a.purgeExpiredQuarantine()
 	return a.app.Run()
 }